@@ -1,11 +1,136 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"expvar"
 	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/rBurgett/scmsh/internal/api"
+	"github.com/rBurgett/scmsh/internal/config"
+	"github.com/rBurgett/scmsh/internal/service"
+	"github.com/rBurgett/scmsh/internal/storage"
+)
+
+const (
+	shutdownTimeout = 10 * time.Second
 )
 
 func main() {
-	fmt.Println("scmsh!")
+	if len(os.Args) < 2 || os.Args[1] != "serve" {
+		fmt.Println("scmsh!")
+
+		fmt.Println("This is scmsh without R and I") // added by Isaac
+
+		fmt.Println("usage: scmsh serve")
+		return
+	}
+
+	if err := serve(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func serve() (err error) {
+	cfg, err := config.Get()
+	if err != nil {
+		return err
+	}
+
+	driver, err := storage.NewDriver(cfg)
+	if err != nil {
+		return err
+	}
+	if c, ok := driver.(io.Closer); ok {
+		// Closing may save the last of the data, so it failing fails serve.
+		defer func() {
+			if closeErr := c.Close(); closeErr != nil && err == nil {
+				err = fmt.Errorf("close storage: %w", closeErr)
+			}
+		}()
+	}
+	if cache, ok := driver.(*storage.CacheDriver); ok {
+		expvar.Publish("storage_cache", expvar.Func(func() any {
+			return cache.Stats()
+		}))
+	}
+	codec, err := storage.CodecByName(cfg.StorageCodec)
+	if err != nil {
+		return err
+	}
+
+	bus := service.NewEventBus()
+	expvar.Publish("events_dropped", expvar.Func(func() any {
+		return bus.Dropped()
+	}))
+	playerClient := storage.NewClient[service.Player](driver, service.PlayersNamespace, storage.WithCodec(codec))
+	games := service.NewGameManager(
+		*storage.NewClient[service.Game](driver, service.GamesNamespace, storage.WithCodec(codec)),
+		service.WithEventBus(bus),
+		service.WithOpenGameTTL(cfg.OpenGameTTL),
+		service.WithFinishedGameRetention(cfg.FinishedGameRetention),
+		service.WithArchive(*storage.NewClient[service.Game](driver, service.GamesArchiveNamespace, storage.WithCodec(codec))),
+		service.WithPlayerProfiles(*playerClient),
+	)
+	players := service.NewPlayerManager(*playerClient)
+
+	servers := []*http.Server{{
+		Addr:    fmt.Sprintf(":%d", cfg.Port),
+		Handler: api.NewServer(games, players, bus),
+	}}
+	if cfg.AdminPort > 0 {
+		servers = append(servers, &http.Server{
+			Addr:    fmt.Sprintf(":%d", cfg.AdminPort),
+			Handler: adminHandler(),
+		})
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err = games.Reindex(ctx); err != nil {
+		return err
+	}
+
+	errs := make(chan error, len(servers))
+	for _, srv := range servers {
+		go func() {
+			log.Printf("listening on %s", srv.Addr)
+			errs <- srv.ListenAndServe()
+		}()
+	}
+
+	select {
+	case err = <-errs:
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	for _, srv := range servers {
+		shutdownErr := srv.Shutdown(shutdownCtx)
+		if err == nil && !errors.Is(shutdownErr, http.ErrServerClosed) {
+			err = shutdownErr
+		}
+	}
+
+	return err
+}
+
+// adminHandler serves the expvar metrics under /debug/vars. It is kept off
+// the API's listener so the metrics are only reachable where the admin port
+// is.
+func adminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /debug/vars", expvar.Handler())
 
-	fmt.Println("This is scmsh without R and I") // added by Isaac
+	return mux
 }
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/rBurgett/scmsh/internal/constants"
+)
+
+var (
+	ErrorInvalidBody   = errors.New("invalid request body")
+	ErrorInvalidResume = errors.New("invalid resume point")
+)
+
+var statusCodes = []struct {
+	err    error
+	status int
+}{
+	{constants.ErrorGameNotFound, http.StatusNotFound},
+	{constants.ErrorPlayerNotFound, http.StatusNotFound},
+	{constants.ErrorNotFound, http.StatusNotFound},
+
+	{constants.ErrorPlayerInvalidSecret, http.StatusUnauthorized},
+
+	{constants.ErrorPlayerNotOwner, http.StatusForbidden},
+	{constants.ErrorPlayerWrongTurn, http.StatusForbidden},
+
+	{constants.ErrorConflict, http.StatusConflict},
+	{constants.ErrorGameAlreadyStarted, http.StatusConflict},
+	{constants.ErrorGameNotStarted, http.StatusConflict},
+	{constants.ErrorGameOver, http.StatusConflict},
+	{constants.ErrorNotEnoughPlayers, http.StatusConflict},
+	{constants.ErrorPlayerAlreadyJoined, http.StatusConflict},
+	{constants.ErrorPlayerNotAccepted, http.StatusConflict},
+	{constants.ErrorPlayerNotRequested, http.StatusConflict},
+	{constants.ErrorPlayersNotReady, http.StatusConflict},
+
+	{ErrorInvalidBody, http.StatusBadRequest},
+	{ErrorInvalidResume, http.StatusBadRequest},
+	{constants.ErrorEmptyStack, http.StatusBadRequest},
+	{constants.ErrorIllegalMove, http.StatusBadRequest},
+	{constants.ErrorInvalidCardCount, http.StatusBadRequest},
+	{constants.ErrorInvalidCardType, http.StatusBadRequest},
+	{constants.ErrorInvalidDeckCounts, http.StatusBadRequest},
+	{constants.ErrorInvalidRuleset, http.StatusBadRequest},
+	{constants.ErrorInvalidStack, http.StatusBadRequest},
+	{constants.ErrorPlayerInvalid, http.StatusBadRequest},
+	{constants.ErrorPlayerInvalidID, http.StatusBadRequest},
+	{constants.ErrorPlayerInvalidName, http.StatusBadRequest},
+}
+
+// StatusCode maps an error coming out of the service layer onto the HTTP
+// status code it should be reported with.
+func StatusCode(err error) int {
+	for _, sc := range statusCodes {
+		if errors.Is(err, sc.err) {
+			return sc.status
+		}
+	}
+
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/rBurgett/scmsh/internal/constants"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{
+			name:     "not found",
+			err:      constants.ErrorGameNotFound,
+			expected: http.StatusNotFound,
+		},
+		{
+			name:     "invalid secret",
+			err:      constants.ErrorPlayerInvalidSecret,
+			expected: http.StatusUnauthorized,
+		},
+		{
+			name:     "not owner",
+			err:      constants.ErrorPlayerNotOwner,
+			expected: http.StatusForbidden,
+		},
+		{
+			name:     "conflict",
+			err:      constants.ErrorPlayersNotReady,
+			expected: http.StatusConflict,
+		},
+		{
+			name:     "wrapped bad request",
+			err:      pkgerrors.Wrap(constants.ErrorInvalidRuleset, "missing matchup"),
+			expected: http.StatusBadRequest,
+		},
+		{
+			name:     "unknown",
+			err:      errors.New("boom"),
+			expected: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, StatusCode(tt.err))
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/rBurgett/scmsh/internal/constants"
+	"github.com/rBurgett/scmsh/internal/service"
+)
+
+type EventType string
+
+const (
+	EventTypeLobby    EventType = "lobby"
+	EventTypeRemoved  EventType = "removed"
+	EventTypeMove     EventType = "move"
+	EventTypeTurn     EventType = "turn"
+	EventTypeGameOver EventType = "game_over"
+
+	subscriberBuffer = 64
+)
+
+// Event is what streaming clients receive. ID is the number of moves played
+// when the event happened, so a client can resume from the last ID it saw.
+type Event struct {
+	ID   int
+	Type EventType
+	Game service.GameView
+	Move *service.Move
+}
+
+// update is an event before it has been projected for a particular viewer.
+type update struct {
+	ID   int
+	Type EventType
+	Game service.Game
+	Move *service.Move
+}
+
+// eventFor projects the update for the viewer. The move is taken from the
+// projected game, so it is redacted the same way as the game's moves are.
+func (u update) eventFor(viewerID uuid.UUID) Event {
+	e := Event{
+		ID:   u.ID,
+		Type: u.Type,
+		Game: u.Game.ViewFor(viewerID),
+	}
+	if u.Move != nil && u.ID >= 1 && u.ID <= len(e.Game.Moves) {
+		m := e.Game.Moves[u.ID-1]
+		e.Move = &m
+	}
+
+	return e
+}
+
+// replayUpdates rebuilds the move events a client missed after the given move
+// count. They all carry the current state of the game.
+func replayUpdates(g service.Game, since int) []update {
+	var res []update
+	for i := max(since, 0); i < len(g.Moves); i++ {
+		res = append(res, update{ID: i + 1, Type: EventTypeMove, Game: g, Move: &g.Moves[i]})
+	}
+
+	return res
+}
+
+// statusUpdate is the event describing where the game currently stands.
+func statusUpdate(g service.Game) update {
+	u := update{ID: len(g.Moves), Game: g}
+	switch g.Status {
+	case constants.GameStatusOpen:
+		u.Type = EventTypeLobby
+	case constants.GameStatusStarted:
+		u.Type = EventTypeTurn
+	default:
+		u.Type = EventTypeGameOver
+	}
+
+	return u
+}
+
+// updatesFor works out which stream events a domain event produces. A game
+// that ends publishes EventGameFinished, which is where game_over comes from.
+// An open game that everybody left has been deleted, so it is removed instead.
+func updatesFor(e service.Event) []update {
+	g := e.Game
+	switch e.Type {
+	case service.EventMoveExecuted:
+		res := []update{{ID: len(g.Moves), Type: EventTypeMove, Game: g, Move: e.Move}}
+		if g.Status == constants.GameStatusStarted {
+			res = append(res, statusUpdate(g))
+		}
+		return res
+	case service.EventPlayerEliminated:
+		return nil
+	case service.EventGameFinished:
+		return []update{statusUpdate(g)}
+	default:
+		if g.Status == constants.GameStatusDone {
+			return nil
+		}
+		if g.IsAbandoned() {
+			return []update{{ID: len(g.Moves), Type: EventTypeRemoved, Game: g}}
+		}
+		return []update{statusUpdate(g)}
+	}
+}
+
+type subscriber struct {
+	updates chan update
+}
+
+// hub fans updates out to everybody subscribed to a game, and to everybody
+// subscribed to uuid.Nil, which follows all games. Subscribers that fall too
+// far behind are dropped and have to reconnect and resume.
+type hub struct {
+	m    sync.Mutex
+	subs map[uuid.UUID]map[*subscriber]struct{}
+}
+
+func (h *hub) subscribe(gameID uuid.UUID) *subscriber {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	s := &subscriber{
+		updates: make(chan update, subscriberBuffer),
+	}
+	if h.subs[gameID] == nil {
+		h.subs[gameID] = map[*subscriber]struct{}{}
+	}
+	h.subs[gameID][s] = struct{}{}
+
+	return s
+}
+
+func (h *hub) unsubscribe(gameID uuid.UUID, s *subscriber) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	if _, ok := h.subs[gameID][s]; !ok {
+		return
+	}
+	delete(h.subs[gameID], s)
+	if len(h.subs[gameID]) == 0 {
+		delete(h.subs, gameID)
+	}
+	close(s.updates)
+}
+
+func (h *hub) publish(updates ...update) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	for _, u := range updates {
+		for _, key := range []uuid.UUID{u.Game.ID, uuid.Nil} {
+			for s := range h.subs[key] {
+				select {
+				case s.updates <- u:
+				default:
+					delete(h.subs[key], s)
+					close(s.updates)
+				}
+			}
+		}
+	}
+}
+
+func newHub() *hub {
+	return &hub{
+		subs: map[uuid.UUID]map[*subscriber]struct{}{},
+	}
+}
@@ -0,0 +1,397 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/rBurgett/scmsh/internal/constants"
+	"github.com/rBurgett/scmsh/internal/service"
+)
+
+const (
+	PlayerIDHeader     = "X-Player-ID"
+	PlayerSecretHeader = "X-Player-Secret"
+)
+
+type Server struct {
+	games   *service.GameManager
+	players *service.PlayerManager
+	hub     *hub
+	mux     *http.ServeMux
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) routes() {
+	s.mux.HandleFunc("POST /players", s.handleCreatePlayer)
+
+	s.mux.HandleFunc("GET /games", s.handleListGames)
+	s.mux.HandleFunc("POST /games", s.handleCreateGame)
+	s.mux.HandleFunc("GET /games/{id}", s.handleGetGame)
+	s.mux.HandleFunc("POST /games/{id}/join", s.handleJoin)
+	s.mux.HandleFunc("POST /games/{id}/accept", s.handleAccept)
+	s.mux.HandleFunc("POST /games/{id}/reject", s.handleReject)
+	s.mux.HandleFunc("PUT /games/{id}/ruleset", s.handleSetRuleset)
+	s.mux.HandleFunc("PUT /games/{id}/deck", s.handleSubmitDeck)
+	s.mux.HandleFunc("POST /games/{id}/ready", s.handleReady)
+	s.mux.HandleFunc("POST /games/{id}/leave", s.handleLeave)
+	s.mux.HandleFunc("POST /games/{id}/start", s.handleStart)
+	s.mux.HandleFunc("GET /games/{id}/moves/legal", s.handleLegalMoves)
+	s.mux.HandleFunc("POST /games/{id}/moves", s.handleMove)
+	s.mux.HandleFunc("GET /games/{id}/ws", s.handleGameWebSocket)
+	s.mux.HandleFunc("GET /games/{id}/events", s.handleGameEvents)
+	s.mux.HandleFunc("GET /games/events", s.handleLobbyEvents)
+}
+
+type createPlayerRequest struct {
+	Name string
+}
+
+type playerRequest struct {
+	Player uuid.UUID
+}
+
+type deckRequest struct {
+	Deck [][]constants.CardType
+}
+
+type moveRequest struct {
+	PlayerCardPosition       int
+	TargetPlayer             uuid.UUID
+	TargetPlayerCardPosition int
+}
+
+type errorResponse struct {
+	Error string
+}
+
+func (s *Server) handleCreatePlayer(w http.ResponseWriter, r *http.Request) {
+	var req createPlayerRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	p, err := s.players.CreatePlayer(r.Context(), req.Name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, p)
+}
+
+// handleListGames lists every game, or only the open ones with ?status=open,
+// or only the ones a player is in with ?player=<id>.
+func (s *Server) handleListGames(w http.ResponseWriter, r *http.Request) {
+	var games []service.Game
+	var err error
+	query := r.URL.Query()
+	switch {
+	case query.Has("player"):
+		var playerID uuid.UUID
+		playerID, err = uuid.Parse(query.Get("player"))
+		if err != nil {
+			writeError(w, constants.ErrorPlayerInvalidID)
+			return
+		}
+		games, err = s.games.ListGamesForPlayer(r.Context(), playerID)
+	case query.Get("status") == "open":
+		games, err = s.games.ListOpenGames(r.Context())
+	default:
+		games, err = s.games.ListGames(r.Context())
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	views := make([]service.GameView, len(games))
+	for i, g := range games {
+		views[i] = g.SpectatorView()
+	}
+
+	writeJSON(w, http.StatusOK, views)
+}
+
+func (s *Server) handleCreateGame(w http.ResponseWriter, r *http.Request) {
+	p, err := s.authenticate(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	g, err := s.games.CreateGame(r.Context(), p)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, g.ViewFor(p.ID))
+}
+
+func (s *Server) handleGetGame(w http.ResponseWriter, r *http.Request) {
+	gameID, err := pathID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	playerID, secret, err := credentials(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	view, err := s.games.GetView(r.Context(), gameID, playerID, secret)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, view)
+}
+
+func (s *Server) handleJoin(w http.ResponseWriter, r *http.Request) {
+	p, err := s.authenticate(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	s.respondWithGame(w, r, p.ID, func(gameID uuid.UUID) (service.Game, error) {
+		return s.games.RequestJoin(r.Context(), gameID, p)
+	})
+}
+
+func (s *Server) handleAccept(w http.ResponseWriter, r *http.Request) {
+	var req playerRequest
+	s.playerAction(w, r, &req, func(gameID uuid.UUID, playerID uuid.UUID, secret uuid.UUID) (service.Game, error) {
+		return s.games.Accept(r.Context(), gameID, playerID, secret, req.Player)
+	})
+}
+
+func (s *Server) handleReject(w http.ResponseWriter, r *http.Request) {
+	var req playerRequest
+	s.playerAction(w, r, &req, func(gameID uuid.UUID, playerID uuid.UUID, secret uuid.UUID) (service.Game, error) {
+		return s.games.Reject(r.Context(), gameID, playerID, secret, req.Player)
+	})
+}
+
+func (s *Server) handleSetRuleset(w http.ResponseWriter, r *http.Request) {
+	var req service.Ruleset
+	s.playerAction(w, r, &req, func(gameID uuid.UUID, playerID uuid.UUID, secret uuid.UUID) (service.Game, error) {
+		return s.games.SetRuleset(r.Context(), gameID, playerID, secret, req)
+	})
+}
+
+func (s *Server) handleSubmitDeck(w http.ResponseWriter, r *http.Request) {
+	var req deckRequest
+	s.playerAction(w, r, &req, func(gameID uuid.UUID, playerID uuid.UUID, secret uuid.UUID) (service.Game, error) {
+		return s.games.SubmitDeck(r.Context(), gameID, playerID, secret, req.Deck)
+	})
+}
+
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	s.playerAction(w, r, nil, func(gameID uuid.UUID, playerID uuid.UUID, secret uuid.UUID) (service.Game, error) {
+		return s.games.SetReady(r.Context(), gameID, playerID, secret)
+	})
+}
+
+func (s *Server) handleLeave(w http.ResponseWriter, r *http.Request) {
+	s.playerAction(w, r, nil, func(gameID uuid.UUID, playerID uuid.UUID, secret uuid.UUID) (service.Game, error) {
+		return s.games.Leave(r.Context(), gameID, playerID, secret)
+	})
+}
+
+func (s *Server) handleStart(w http.ResponseWriter, r *http.Request) {
+	s.playerAction(w, r, nil, func(gameID uuid.UUID, playerID uuid.UUID, secret uuid.UUID) (service.Game, error) {
+		return s.games.StartGame(r.Context(), gameID, playerID, secret)
+	})
+}
+
+func (s *Server) handleLegalMoves(w http.ResponseWriter, r *http.Request) {
+	gameID, err := pathID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	playerID, secret, err := playerCredentials(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	moves, err := s.games.LegalMoves(r.Context(), gameID, playerID, secret)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, moves)
+}
+
+func (s *Server) handleMove(w http.ResponseWriter, r *http.Request) {
+	gameID, err := pathID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	playerID, secret, err := playerCredentials(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var req moveRequest
+	if err = decodeBody(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	m, err := s.games.ExecuteMove(r.Context(), gameID, playerID, secret, req.PlayerCardPosition, req.TargetPlayer, req.TargetPlayerCardPosition)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, m)
+}
+
+// playerAction handles the common shape of a lobby action: decode the body
+// into req (if any), run fn with the caller's credentials and respond with the
+// caller's view of the resulting game.
+func (s *Server) playerAction(w http.ResponseWriter, r *http.Request, req any, fn func(gameID uuid.UUID, playerID uuid.UUID, secret uuid.UUID) (service.Game, error)) {
+	playerID, secret, err := playerCredentials(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if req != nil {
+		if err = decodeBody(r, req); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	s.respondWithGame(w, r, playerID, func(gameID uuid.UUID) (service.Game, error) {
+		return fn(gameID, playerID, secret)
+	})
+}
+
+func (s *Server) respondWithGame(w http.ResponseWriter, r *http.Request, viewerID uuid.UUID, fn func(gameID uuid.UUID) (service.Game, error)) {
+	gameID, err := pathID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	g, err := fn(gameID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, g.ViewFor(viewerID))
+}
+
+// authenticate loads the calling player's profile and checks their secret.
+func (s *Server) authenticate(r *http.Request) (service.Player, error) {
+	playerID, secret, err := playerCredentials(r)
+	if err != nil {
+		return service.Player{}, err
+	}
+
+	return s.players.Authenticate(r.Context(), playerID, secret)
+}
+
+// playerCredentials is credentials for actions only a player can take, so
+// anonymous requests are turned away.
+func playerCredentials(r *http.Request) (uuid.UUID, uuid.UUID, error) {
+	playerID, secret, err := credentials(r)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	if playerID == uuid.Nil {
+		return uuid.Nil, uuid.Nil, constants.ErrorPlayerInvalidSecret
+	}
+
+	return playerID, secret, nil
+}
+
+// credentials reads the caller's player ID and secret from the request
+// headers. Both are uuid.Nil for anonymous requests.
+func credentials(r *http.Request) (uuid.UUID, uuid.UUID, error) {
+	idStr := r.Header.Get(PlayerIDHeader)
+	if idStr == "" {
+		return uuid.Nil, uuid.Nil, nil
+	}
+
+	playerID, err := uuid.Parse(idStr)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, constants.ErrorPlayerInvalidID
+	}
+	secret, err := uuid.Parse(r.Header.Get(PlayerSecretHeader))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, constants.ErrorPlayerInvalidSecret
+	}
+
+	return playerID, secret, nil
+}
+
+func pathID(r *http.Request) (uuid.UUID, error) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		return uuid.Nil, constants.ErrorGameNotFound
+	}
+
+	return id, nil
+}
+
+func decodeBody(r *http.Request, v any) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return errors.Wrap(ErrorInvalidBody, err.Error())
+	}
+
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := StatusCode(err)
+	msg := err.Error()
+	if status == http.StatusInternalServerError {
+		msg = http.StatusText(status)
+	}
+
+	writeJSON(w, status, errorResponse{Error: msg})
+}
+
+// NewServer serves the given managers. Live streams are fed from bus, which
+// has to be the bus games publishes to.
+func NewServer(games *service.GameManager, players *service.PlayerManager, bus *service.EventBus) *Server {
+	s := &Server{
+		games:   games,
+		players: players,
+		hub:     newHub(),
+		mux:     http.NewServeMux(),
+	}
+	s.routes()
+	bus.Subscribe(service.SubscriberFunc(func(_ context.Context, e service.Event) {
+		s.hub.publish(updatesFor(e)...)
+	}))
+
+	return s
+}
@@ -0,0 +1,241 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rBurgett/scmsh/internal/constants"
+	"github.com/rBurgett/scmsh/internal/service"
+	"github.com/rBurgett/scmsh/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	driver := storage.NewMemDriver()
+	bus := service.NewEventBus()
+	games := service.NewGameManager(*storage.NewClient[service.Game](driver, service.GamesNamespace), service.WithEventBus(bus))
+	players := service.NewPlayerManager(*storage.NewClient[service.Player](driver, service.PlayersNamespace))
+
+	srv := httptest.NewServer(NewServer(games, players, bus))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func doRequest(t *testing.T, srv *httptest.Server, method string, path string, p *service.Player, body any, out any) int {
+	var buf bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&buf).Encode(body))
+	}
+
+	req, err := http.NewRequest(method, srv.URL+path, &buf)
+	require.NoError(t, err)
+	if p != nil {
+		req.Header.Set(PlayerIDHeader, p.ID.String())
+		req.Header.Set(PlayerSecretHeader, p.Secret.String())
+	}
+
+	res, err := srv.Client().Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	if out != nil {
+		require.NoError(t, json.NewDecoder(res.Body).Decode(out))
+	}
+
+	return res.StatusCode
+}
+
+func createTestPlayer(t *testing.T, srv *httptest.Server, name string) service.Player {
+	var p service.Player
+	status := doRequest(t, srv, http.MethodPost, "/players", nil, createPlayerRequest{Name: name}, &p)
+	require.Equal(t, http.StatusCreated, status)
+
+	return p
+}
+
+// newTestDeck deals the default inventory in card order, which keeps the
+// Crown at the bottom of the last stack.
+func newTestDeck() [][]constants.CardType {
+	var cards []constants.CardType
+	for _, card := range constants.ValidCards {
+		for range constants.GetCardCount(card) {
+			cards = append(cards, card)
+		}
+	}
+
+	var deck [][]constants.CardType
+	for i := 0; i < len(cards); i += constants.DeckCardCount {
+		deck = append(deck, cards[i:i+constants.DeckCardCount])
+	}
+
+	return deck
+}
+
+// startTestGame creates a two player game and runs it through the lobby.
+func startTestGame(t *testing.T, srv *httptest.Server) (service.Player, service.Player, string) {
+	owner := createTestPlayer(t, srv, "Ryan")
+	joiner := createTestPlayer(t, srv, "Isaac")
+
+	var view service.GameView
+	status := doRequest(t, srv, http.MethodPost, "/games", &owner, nil, &view)
+	require.Equal(t, http.StatusCreated, status)
+	gamePath := "/games/" + view.ID.String()
+
+	status = doRequest(t, srv, http.MethodPost, gamePath+"/join", &joiner, nil, nil)
+	require.Equal(t, http.StatusOK, status)
+	status = doRequest(t, srv, http.MethodPost, gamePath+"/accept", &owner, playerRequest{Player: joiner.ID}, nil)
+	require.Equal(t, http.StatusOK, status)
+	for _, p := range []*service.Player{&owner, &joiner} {
+		status = doRequest(t, srv, http.MethodPut, gamePath+"/deck", p, deckRequest{Deck: newTestDeck()}, nil)
+		require.Equal(t, http.StatusOK, status)
+		status = doRequest(t, srv, http.MethodPost, gamePath+"/ready", p, nil, nil)
+		require.Equal(t, http.StatusOK, status)
+	}
+	status = doRequest(t, srv, http.MethodPost, gamePath+"/start", &owner, nil, nil)
+	require.Equal(t, http.StatusOK, status)
+
+	return owner, joiner, gamePath
+}
+
+// playTestMove plays the first legal move for the player.
+func playTestMove(t *testing.T, srv *httptest.Server, gamePath string, p *service.Player) service.Move {
+	var legal []service.LegalMove
+	status := doRequest(t, srv, http.MethodGet, gamePath+"/moves/legal", p, nil, &legal)
+	require.Equal(t, http.StatusOK, status)
+	require.NotEmpty(t, legal)
+
+	var move service.Move
+	status = doRequest(t, srv, http.MethodPost, gamePath+"/moves", p, moveRequest{
+		PlayerCardPosition:       legal[0].PlayerCardPosition,
+		TargetPlayer:             legal[0].TargetPlayer,
+		TargetPlayerCardPosition: legal[0].TargetPlayerCardPosition,
+	}, &move)
+	require.Equal(t, http.StatusCreated, status)
+
+	return move
+}
+
+func TestServer_gameLifecycle(t *testing.T) {
+	srv := newTestServer(t)
+
+	owner := createTestPlayer(t, srv, "Ryan")
+	joiner := createTestPlayer(t, srv, "Isaac")
+
+	var view service.GameView
+	status := doRequest(t, srv, http.MethodPost, "/games", &owner, nil, &view)
+	require.Equal(t, http.StatusCreated, status)
+	assert.Equal(t, owner.ID, view.Viewer)
+	gamePath := "/games/" + view.ID.String()
+
+	var views []service.GameView
+	status = doRequest(t, srv, http.MethodGet, "/games", nil, nil, &views)
+	require.Equal(t, http.StatusOK, status)
+	assert.Len(t, views, 1)
+
+	status = doRequest(t, srv, http.MethodPost, gamePath+"/join", &joiner, nil, &view)
+	require.Equal(t, http.StatusOK, status)
+
+	status = doRequest(t, srv, http.MethodPost, gamePath+"/accept", &joiner, playerRequest{Player: joiner.ID}, nil)
+	require.Equal(t, http.StatusForbidden, status)
+
+	status = doRequest(t, srv, http.MethodPost, gamePath+"/accept", &owner, playerRequest{Player: joiner.ID}, &view)
+	require.Equal(t, http.StatusOK, status)
+
+	for _, p := range []*service.Player{&owner, &joiner} {
+		status = doRequest(t, srv, http.MethodPut, gamePath+"/deck", p, deckRequest{Deck: newTestDeck()}, nil)
+		require.Equal(t, http.StatusOK, status)
+		status = doRequest(t, srv, http.MethodPost, gamePath+"/ready", p, nil, nil)
+		require.Equal(t, http.StatusOK, status)
+	}
+
+	status = doRequest(t, srv, http.MethodGet, "/games?player="+joiner.ID.String(), nil, nil, &views)
+	require.Equal(t, http.StatusOK, status)
+	assert.Len(t, views, 1)
+
+	status = doRequest(t, srv, http.MethodPost, gamePath+"/start", &owner, nil, &view)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, owner.ID, view.CurrentPlayer)
+
+	status = doRequest(t, srv, http.MethodGet, "/games?status=open", nil, nil, &views)
+	require.Equal(t, http.StatusOK, status)
+	assert.Empty(t, views)
+
+	var legal []service.LegalMove
+	status = doRequest(t, srv, http.MethodGet, gamePath+"/moves/legal", &owner, nil, &legal)
+	require.Equal(t, http.StatusOK, status)
+	require.NotEmpty(t, legal)
+
+	status = doRequest(t, srv, http.MethodPost, gamePath+"/moves", &joiner, moveRequest{TargetPlayer: owner.ID}, nil)
+	require.Equal(t, http.StatusForbidden, status)
+
+	var move service.Move
+	status = doRequest(t, srv, http.MethodPost, gamePath+"/moves", &owner, moveRequest{
+		PlayerCardPosition:       legal[0].PlayerCardPosition,
+		TargetPlayer:             legal[0].TargetPlayer,
+		TargetPlayerCardPosition: legal[0].TargetPlayerCardPosition,
+	}, &move)
+	require.Equal(t, http.StatusCreated, status)
+	assert.Equal(t, 1, move.Turn)
+
+	status = doRequest(t, srv, http.MethodGet, gamePath, &joiner, nil, &view)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, joiner.ID, view.Viewer)
+	assert.Len(t, view.Moves, 1)
+	for _, p := range view.Players {
+		for _, s := range p.Stacks {
+			if p.ID == joiner.ID {
+				assert.Len(t, s.Cards, s.Height)
+			} else {
+				assert.LessOrEqual(t, len(s.Cards), 1)
+			}
+		}
+	}
+
+	status = doRequest(t, srv, http.MethodGet, gamePath, nil, nil, &view)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, uuid.Nil, view.Viewer)
+}
+
+func TestServer_errors(t *testing.T) {
+	srv := newTestServer(t)
+
+	owner := createTestPlayer(t, srv, "Ryan")
+	impostor := owner
+	impostor.Secret = uuid.New()
+
+	var res errorResponse
+	status := doRequest(t, srv, http.MethodPost, "/players", nil, createPlayerRequest{Name: ""}, &res)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, constants.ErrorPlayerInvalidName.Error(), res.Error)
+
+	status = doRequest(t, srv, http.MethodPost, "/games", nil, nil, nil)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status = doRequest(t, srv, http.MethodPost, "/games", &impostor, nil, nil)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status = doRequest(t, srv, http.MethodGet, "/games/"+uuid.NewString(), nil, nil, nil)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status = doRequest(t, srv, http.MethodGet, "/games/nope", nil, nil, nil)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	var view service.GameView
+	status = doRequest(t, srv, http.MethodPost, "/games", &owner, nil, &view)
+	require.Equal(t, http.StatusCreated, status)
+	gamePath := "/games/" + view.ID.String()
+
+	// Actions without credentials are turned away before the game is touched.
+	status = doRequest(t, srv, http.MethodPost, gamePath+"/ready", nil, nil, &res)
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, constants.ErrorPlayerInvalidSecret.Error(), res.Error)
+
+	status = doRequest(t, srv, http.MethodGet, gamePath+"/moves/legal", nil, nil, nil)
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+type sseEventWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func (w sseEventWriter) WriteEvent(e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w.w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+	if err != nil {
+		return err
+	}
+	w.flusher.Flush()
+
+	return nil
+}
+
+func (w sseEventWriter) Heartbeat() error {
+	if _, err := fmt.Fprint(w.w, ": heartbeat\n\n"); err != nil {
+		return err
+	}
+	w.flusher.Flush()
+
+	return nil
+}
+
+// handleGameEvents streams a game's events as Server-Sent Events. The
+// Last-Event-ID header, or ?since=N, resumes after the given move.
+func (s *Server) handleGameEvents(w http.ResponseWriter, r *http.Request) {
+	resume := r.Header.Get(lastEventIDHeader)
+	if resume == "" {
+		resume = r.URL.Query().Get("since")
+	}
+	since, err := parseSince(resume)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	st, err := s.openGameStream(r, since)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer s.closeStream(st)
+
+	s.serveEvents(w, r, st)
+}
+
+// handleLobbyEvents streams the games that are open to join as Server-Sent
+// Events.
+func (s *Server) handleLobbyEvents(w http.ResponseWriter, r *http.Request) {
+	st, err := s.openLobbyStream(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer s.closeStream(st)
+
+	s.serveEvents(w, r, st)
+}
+
+func (s *Server) serveEvents(w http.ResponseWriter, r *http.Request, st *stream) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	st.run(r.Context(), sseEventWriter{w: w, flusher: flusher}, heartbeatInterval)
+}
@@ -0,0 +1,217 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rBurgett/scmsh/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testSSEClient struct {
+	events chan Event
+}
+
+func dialTestSSE(t *testing.T, srv *httptest.Server, path string, header http.Header) *testSSEClient {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+path, nil)
+	require.NoError(t, err)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	res, err := srv.Client().Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	c := &testSSEClient{
+		events: make(chan Event, 16),
+	}
+	go func() {
+		defer res.Body.Close()
+
+		var id, eventType, data string
+		scanner := bufio.NewScanner(res.Body)
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				eventType = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				data = strings.TrimPrefix(line, "data: ")
+			case line == "" && data != "":
+				var e Event
+				if json.Unmarshal([]byte(data), &e) == nil &&
+					strconv.Itoa(e.ID) == id && string(e.Type) == eventType {
+					c.events <- e
+				}
+				id, eventType, data = "", "", ""
+			}
+		}
+	}()
+
+	return c
+}
+
+func (c *testSSEClient) readEvent(t *testing.T) Event {
+	select {
+	case e := <-c.events:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+
+	return Event{}
+}
+
+func TestServer_gameEvents(t *testing.T) {
+	srv := newTestServer(t)
+	owner, joiner, gamePath := startTestGame(t, srv)
+
+	playTestMove(t, srv, gamePath, &owner)
+
+	header := http.Header{}
+	header.Set(lastEventIDHeader, "0")
+	header.Set(PlayerIDHeader, joiner.ID.String())
+	header.Set(PlayerSecretHeader, joiner.Secret.String())
+	c := dialTestSSE(t, srv, gamePath+"/events", header)
+
+	e := c.readEvent(t)
+	assert.Equal(t, EventTypeMove, e.Type)
+	assert.Equal(t, 1, e.ID)
+	assert.Equal(t, joiner.ID, e.Game.Viewer)
+
+	e = c.readEvent(t)
+	assert.Equal(t, EventTypeTurn, e.Type)
+	assert.Equal(t, joiner.ID, e.Game.CurrentPlayer)
+
+	move := playTestMove(t, srv, gamePath, &joiner)
+
+	e = c.readEvent(t)
+	assert.Equal(t, EventTypeMove, e.Type)
+	assert.Equal(t, 2, e.ID)
+	assert.Equal(t, &move, e.Move)
+}
+
+func TestServer_gameEvents_spectator(t *testing.T) {
+	srv := newTestServer(t)
+	owner, joiner, gamePath := startTestGame(t, srv)
+
+	playTestMove(t, srv, gamePath, &owner)
+
+	header := http.Header{}
+	header.Set(lastEventIDHeader, "0")
+	c := dialTestSSE(t, srv, gamePath+"/events", header)
+
+	e := c.readEvent(t)
+	assert.Equal(t, EventTypeMove, e.Type)
+	require.NotNil(t, e.Move)
+	assert.Zero(t, e.Move.PlayerCardType)
+	assert.Zero(t, e.Move.TargetPlayerCardType)
+
+	e = c.readEvent(t)
+	assert.Equal(t, EventTypeTurn, e.Type)
+
+	playTestMove(t, srv, gamePath, &joiner)
+
+	e = c.readEvent(t)
+	assert.Equal(t, EventTypeMove, e.Type)
+	assert.Equal(t, 2, e.ID)
+	require.NotNil(t, e.Move)
+	assert.Equal(t, joiner.ID, e.Move.Player)
+	assert.Zero(t, e.Move.PlayerCardType)
+	assert.Zero(t, e.Move.TargetPlayerCardType)
+}
+
+func TestServer_lobbyEvents(t *testing.T) {
+	srv := newTestServer(t)
+	owner := createTestPlayer(t, srv, "Ryan")
+	joiner := createTestPlayer(t, srv, "Isaac")
+
+	var view service.GameView
+	status := doRequest(t, srv, http.MethodPost, "/games", &owner, nil, &view)
+	require.Equal(t, http.StatusCreated, status)
+
+	c := dialTestSSE(t, srv, "/games/events", nil)
+
+	e := c.readEvent(t)
+	assert.Equal(t, EventTypeLobby, e.Type)
+	assert.Equal(t, view.ID, e.Game.ID)
+
+	status = doRequest(t, srv, http.MethodPost, "/games/"+view.ID.String()+"/join", &joiner, nil, nil)
+	require.Equal(t, http.StatusOK, status)
+
+	e = c.readEvent(t)
+	assert.Equal(t, EventTypeLobby, e.Type)
+	assert.Len(t, e.Game.Players, 2)
+	for _, p := range e.Game.Players {
+		assert.Empty(t, p.Stacks)
+	}
+
+	_, _, gamePath := startTestGame(t, srv)
+	for {
+		e = c.readEvent(t)
+		if e.Type != EventTypeLobby {
+			break
+		}
+	}
+	assert.Equal(t, EventTypeTurn, e.Type)
+	assert.Equal(t, gamePath, "/games/"+e.Game.ID.String())
+}
+
+func TestServer_lobbyEvents_removed(t *testing.T) {
+	srv := newTestServer(t)
+	owner := createTestPlayer(t, srv, "Ryan")
+
+	var view service.GameView
+	status := doRequest(t, srv, http.MethodPost, "/games", &owner, nil, &view)
+	require.Equal(t, http.StatusCreated, status)
+
+	c := dialTestSSE(t, srv, "/games/events", nil)
+
+	e := c.readEvent(t)
+	assert.Equal(t, EventTypeLobby, e.Type)
+	assert.Equal(t, view.ID, e.Game.ID)
+
+	status = doRequest(t, srv, http.MethodPost, "/games/"+view.ID.String()+"/leave", &owner, nil, nil)
+	require.Equal(t, http.StatusOK, status)
+
+	e = c.readEvent(t)
+	assert.Equal(t, EventTypeRemoved, e.Type)
+	assert.Equal(t, view.ID, e.Game.ID)
+}
+
+func TestServer_lobbyEvents_onlyOpen(t *testing.T) {
+	srv := newTestServer(t)
+	startTestGame(t, srv)
+	owner := createTestPlayer(t, srv, "Ryan")
+
+	var view service.GameView
+	status := doRequest(t, srv, http.MethodPost, "/games", &owner, nil, &view)
+	require.Equal(t, http.StatusCreated, status)
+
+	c := dialTestSSE(t, srv, "/games/events", nil)
+
+	e := c.readEvent(t)
+	assert.Equal(t, EventTypeLobby, e.Type)
+	assert.Equal(t, view.ID, e.Game.ID)
+	select {
+	case e = <-c.events:
+		assert.Fail(t, "unexpected event", "%+v", e)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
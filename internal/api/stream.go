@@ -0,0 +1,241 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rBurgett/scmsh/internal/constants"
+)
+
+const (
+	pingInterval      = 30 * time.Second
+	heartbeatInterval = 15 * time.Second
+
+	lastEventIDHeader = "Last-Event-ID"
+)
+
+// eventWriter is a transport that events can be streamed over.
+type eventWriter interface {
+	WriteEvent(e Event) error
+	Heartbeat() error
+}
+
+// stream is a subscription to the hub along with the events a client has to
+// be sent before the live ones.
+type stream struct {
+	key      uuid.UUID
+	sub      *subscriber
+	viewer   uuid.UUID
+	initial  []update
+	lastMove int
+	filter   func(u update) bool
+}
+
+// openGameStream authenticates the viewer and prepares a stream of the
+// game's events. since is the number of moves the client has already seen,
+// or -1 if it is not resuming.
+func (s *Server) openGameStream(r *http.Request, since int) (*stream, error) {
+	gameID, err := pathID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	playerID, secret, err := streamCredentials(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Subscribe before loading the game so nothing slips through between the
+	// snapshot and the live stream.
+	sub := s.hub.subscribe(gameID)
+
+	view, err := s.games.GetView(r.Context(), gameID, playerID, secret)
+	if err != nil {
+		s.hub.unsubscribe(gameID, sub)
+		return nil, err
+	}
+	g, err := s.games.GetGame(r.Context(), gameID)
+	if err != nil {
+		s.hub.unsubscribe(gameID, sub)
+		return nil, err
+	}
+
+	var initial []update
+	if since >= 0 {
+		initial = replayUpdates(g, since)
+	}
+	initial = append(initial, statusUpdate(g))
+
+	return &stream{
+		key:      gameID,
+		sub:      sub,
+		viewer:   view.Viewer,
+		initial:  initial,
+		lastMove: len(g.Moves),
+	}, nil
+}
+
+// openLobbyStream prepares a stream of every game that is open to join. It
+// starts with the currently open games and then follows lobby changes, plus
+// the events that take a game out of the lobby: it starting or being removed.
+func (s *Server) openLobbyStream(r *http.Request) (*stream, error) {
+	sub := s.hub.subscribe(uuid.Nil)
+
+	games, err := s.games.ListOpenGames(r.Context())
+	if err != nil {
+		s.hub.unsubscribe(uuid.Nil, sub)
+		return nil, err
+	}
+
+	var initial []update
+	for _, g := range games {
+		initial = append(initial, statusUpdate(g))
+	}
+
+	return &stream{
+		key:     uuid.Nil,
+		sub:     sub,
+		initial: initial,
+		filter: func(u update) bool {
+			return u.Type == EventTypeLobby || u.Type == EventTypeRemoved || (u.Type != EventTypeMove && u.ID == 0)
+		},
+	}, nil
+}
+
+func (s *Server) closeStream(st *stream) {
+	s.hub.unsubscribe(st.key, st.sub)
+}
+
+// run writes the initial events and then follows the hub until the client
+// goes away or falls behind.
+func (st *stream) run(ctx context.Context, w eventWriter, heartbeat time.Duration) {
+	for _, u := range st.initial {
+		if err := w.WriteEvent(u.eventFor(st.viewer)); err != nil {
+			return
+		}
+	}
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.Heartbeat(); err != nil {
+				return
+			}
+		case u, ok := <-st.sub.updates:
+			if !ok {
+				return
+			}
+			if u.Type == EventTypeMove && u.ID <= st.lastMove {
+				continue
+			}
+			if st.filter != nil && !st.filter(u) {
+				continue
+			}
+			if err := w.WriteEvent(u.eventFor(st.viewer)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+type wsEventWriter struct {
+	conn *wsConn
+}
+
+func (w wsEventWriter) WriteEvent(e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	return w.conn.WriteText(data)
+}
+
+func (w wsEventWriter) Heartbeat() error {
+	return w.conn.Ping()
+}
+
+// handleGameWebSocket streams a game's events over a WebSocket, each one
+// projected for the connected viewer. Clients resuming after a disconnect
+// pass ?since=N with the last event ID they saw to get the moves they missed.
+func (s *Server) handleGameWebSocket(w http.ResponseWriter, r *http.Request) {
+	since, err := parseSince(r.URL.Query().Get("since"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	st, err := s.openGameStream(r, since)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer s.closeStream(st)
+
+	conn, err := upgradeWebSocket(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go func() {
+		defer cancel()
+		for {
+			if _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	st.run(ctx, wsEventWriter{conn: conn}, pingInterval)
+}
+
+// streamCredentials is credentials with a fallback to the player and secret
+// query parameters, since browsers can't set headers on streaming requests.
+func streamCredentials(r *http.Request) (uuid.UUID, uuid.UUID, error) {
+	if r.Header.Get(PlayerIDHeader) != "" {
+		return credentials(r)
+	}
+
+	q := r.URL.Query()
+	if q.Get("player") == "" {
+		return uuid.Nil, uuid.Nil, nil
+	}
+
+	playerID, err := uuid.Parse(q.Get("player"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, constants.ErrorPlayerInvalidID
+	}
+	secret, err := uuid.Parse(q.Get("secret"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, constants.ErrorPlayerInvalidSecret
+	}
+
+	return playerID, secret, nil
+}
+
+// parseSince reads a resume point. -1 means the client is not resuming.
+func parseSince(value string) (int, error) {
+	if value == "" {
+		return -1, nil
+	}
+
+	since, err := strconv.Atoi(value)
+	if err != nil || since < 0 {
+		return 0, ErrorInvalidResume
+	}
+
+	return since, nil
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rBurgett/scmsh/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testWSClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dialTestWebSocket(t *testing.T, srv *httptest.Server, path string, p *service.Player) *testWSClient {
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	req := fmt.Sprintf("GET %s HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: %s\r\nSec-WebSocket-Version: 13\r\n", path, srv.Listener.Addr(), key)
+	if p != nil {
+		req += fmt.Sprintf("%s: %s\r\n%s: %s\r\n", PlayerIDHeader, p.ID, PlayerSecretHeader, p.Secret)
+	}
+	_, err = conn.Write([]byte(req + "\r\n"))
+	require.NoError(t, err)
+
+	r := bufio.NewReader(conn)
+	res, err := http.ReadResponse(r, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, res.StatusCode)
+	require.Equal(t, websocketAccept(key), res.Header.Get("Sec-WebSocket-Accept"))
+
+	return &testWSClient{
+		conn: conn,
+		r:    r,
+	}
+}
+
+func (c *testWSClient) readEvent(t *testing.T) Event {
+	require.NoError(t, c.conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+	for {
+		var header [2]byte
+		_, err := io.ReadFull(c.r, header[:])
+		require.NoError(t, err)
+		require.Zero(t, header[1]&0x80, "server frames must not be masked")
+
+		length := uint64(header[1] & 0x7F)
+		switch length {
+		case 126:
+			var ext [2]byte
+			_, err = io.ReadFull(c.r, ext[:])
+			require.NoError(t, err)
+			length = uint64(binary.BigEndian.Uint16(ext[:]))
+		case 127:
+			var ext [8]byte
+			_, err = io.ReadFull(c.r, ext[:])
+			require.NoError(t, err)
+			length = binary.BigEndian.Uint64(ext[:])
+		}
+
+		payload := make([]byte, length)
+		_, err = io.ReadFull(c.r, payload)
+		require.NoError(t, err)
+
+		if header[0]&0x0F != wsOpText {
+			continue
+		}
+
+		var e Event
+		require.NoError(t, json.Unmarshal(payload, &e))
+		return e
+	}
+}
+
+func (c *testWSClient) writeFrame(t *testing.T, opcode byte, payload []byte) {
+	mask := [4]byte{1, 2, 3, 4}
+	frame := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	_, err := c.conn.Write(frame)
+	require.NoError(t, err)
+}
+
+func TestWebsocketAccept(t *testing.T) {
+	// Example from RFC 6455 section 1.3.
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", websocketAccept("dGhlIHNhbXBsZSBub25jZQ=="))
+}
+
+func TestWsConn_ReadMessage(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	c := &wsConn{
+		conn: server,
+		rw:   bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server)),
+	}
+	tc := &testWSClient{conn: client, r: bufio.NewReader(client)}
+
+	go func() {
+		tc.writeFrame(t, wsOpPing, []byte("hi"))
+		tc.writeFrame(t, wsOpText, []byte("hello"))
+		tc.writeFrame(t, wsOpClose, nil)
+	}()
+	go func() {
+		// Drain the pong.
+		_, _ = io.Copy(io.Discard, tc.r)
+	}()
+
+	msg, err := c.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(msg))
+
+	_, err = c.ReadMessage()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestUpgradeWebSocket_badHandshake(t *testing.T) {
+	srv := newTestServer(t)
+	_, _, gamePath := startTestGame(t, srv)
+
+	res, err := srv.Client().Get(srv.URL + gamePath + "/ws")
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
+
+func TestServer_gameWebSocket(t *testing.T) {
+	srv := newTestServer(t)
+	owner, joiner, gamePath := startTestGame(t, srv)
+
+	ws := dialTestWebSocket(t, srv, gamePath+"/ws", &joiner)
+	e := ws.readEvent(t)
+	assert.Equal(t, EventTypeTurn, e.Type)
+	assert.Equal(t, 0, e.ID)
+	assert.Equal(t, joiner.ID, e.Game.Viewer)
+
+	spectator := dialTestWebSocket(t, srv, gamePath+"/ws", nil)
+	e = spectator.readEvent(t)
+	assert.Equal(t, EventTypeTurn, e.Type)
+
+	move := playTestMove(t, srv, gamePath, &owner)
+	// Spectators never see which cards were played.
+	redacted := move
+	redacted.PlayerCardType = 0
+	redacted.TargetPlayerCardType = 0
+
+	for _, tc := range []struct {
+		client   *testWSClient
+		expected service.Move
+	}{{ws, move}, {spectator, redacted}} {
+		c := tc.client
+		e = c.readEvent(t)
+		assert.Equal(t, EventTypeMove, e.Type)
+		assert.Equal(t, 1, e.ID)
+		assert.Equal(t, &tc.expected, e.Move)
+
+		e = c.readEvent(t)
+		assert.Equal(t, EventTypeTurn, e.Type)
+		assert.Equal(t, joiner.ID, e.Game.CurrentPlayer)
+	}
+
+	for _, p := range e.Game.Players {
+		for _, s := range p.Stacks {
+			assert.LessOrEqual(t, len(s.Cards), 1)
+		}
+	}
+
+	ws.writeFrame(t, wsOpClose, nil)
+}
+
+func TestServer_gameWebSocket_resume(t *testing.T) {
+	srv := newTestServer(t)
+	owner, joiner, gamePath := startTestGame(t, srv)
+
+	first := playTestMove(t, srv, gamePath, &owner)
+	second := playTestMove(t, srv, gamePath, &joiner)
+
+	ws := dialTestWebSocket(t, srv, gamePath+"/ws?since=1", &owner)
+
+	e := ws.readEvent(t)
+	assert.Equal(t, EventTypeMove, e.Type)
+	assert.Equal(t, 2, e.ID)
+	assert.Equal(t, &second, e.Move)
+
+	e = ws.readEvent(t)
+	assert.Equal(t, EventTypeTurn, e.Type)
+	assert.Equal(t, 2, e.ID)
+	assert.NotEqual(t, first, second)
+}
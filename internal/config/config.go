@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/pkg/errors"
@@ -11,18 +12,54 @@ import (
 
 const (
 	ScmshPortKey          = "SCMSH_PORT"
+	ScmshAdminPortKey     = "SCMSH_ADMIN_PORT"
 	ScmshRedisEnabledKey  = "SCMSH_REDIS_ENABLED"
 	ScmshRedisAddressKey  = "SCMSH_REDIS_ADDRESS"
 	ScmshRedisPasswordKey = "SCMSH_REDIS_PASSWORD"
 	ScmshRedisDatabaseKey = "SCMSH_REDIS_DATABASE"
+	ScmshFileEnabledKey   = "SCMSH_FILE_ENABLED"
+	ScmshFilePathKey      = "SCMSH_FILE_PATH"
+
+	ScmshMemorySnapshotPathKey     = "SCMSH_MEMORY_SNAPSHOT_PATH"
+	ScmshMemorySnapshotIntervalKey = "SCMSH_MEMORY_SNAPSHOT_INTERVAL"
+
+	ScmshOpenGameTTLKey           = "SCMSH_OPEN_GAME_TTL"
+	ScmshFinishedGameRetentionKey = "SCMSH_FINISHED_GAME_RETENTION"
+	ScmshStorageCodecKey          = "SCMSH_STORAGE_CODEC"
+	ScmshCacheSizeKey             = "SCMSH_CACHE_SIZE"
+	ScmshCacheTTLKey              = "SCMSH_CACHE_TTL"
 )
 
 type Config struct {
-	Port          int
+	Port int
+	// AdminPort is where the expvar metrics are served under /debug/vars,
+	// apart from the API. 0 turns them off.
+	AdminPort     int
 	RedisEnabled  bool
 	RedisAddress  string
 	RedisPassword string
 	RedisDatabase int
+	// FileEnabled stores everything in the log at FilePath rather than in
+	// memory. It cannot be combined with RedisEnabled.
+	FileEnabled bool
+	FilePath    string
+	// MemorySnapshotPath is where the memory driver saves its records every
+	// MemorySnapshotInterval and at shutdown, and loads them from at startup.
+	// Empty turns snapshots off.
+	MemorySnapshotPath     string
+	MemorySnapshotInterval time.Duration
+	// OpenGameTTL is how long a lobby nobody touches is kept, and
+	// FinishedGameRetention how long a finished game stays in the live games
+	// before only its archived copy is left. 0 keeps them forever.
+	OpenGameTTL           time.Duration
+	FinishedGameRetention time.Duration
+	// StorageCodec names the codec records are written with: json, gob or
+	// json+gzip.
+	StorageCodec string
+	// CacheSize is how many records are cached in front of the storage
+	// driver, for up to CacheTTL each. 0 turns the cache off.
+	CacheSize int
+	CacheTTL  time.Duration
 }
 
 func Get() (Config, error) {
@@ -39,6 +76,14 @@ func Get() (Config, error) {
 		}
 	}
 
+	adminPort := 0
+	if portSTR := os.Getenv(ScmshAdminPortKey); portSTR != "" {
+		adminPort, err = strconv.Atoi(portSTR)
+		if err != nil {
+			return Config{}, errors.Wrapf(err, "invalid admin port value %q", portSTR)
+		}
+	}
+
 	redisEnabled := false
 	if enabledStr := os.Getenv(ScmshRedisEnabledKey); enabledStr != "" {
 		redisEnabled, err = strconv.ParseBool(enabledStr)
@@ -65,11 +110,84 @@ func Get() (Config, error) {
 		}
 	}
 
+	fileEnabled := false
+	if enabledStr := os.Getenv(ScmshFileEnabledKey); enabledStr != "" {
+		fileEnabled, err = strconv.ParseBool(enabledStr)
+		if err != nil {
+			return Config{}, errors.Wrapf(err, "invalid file enabled value %q", enabledStr)
+		}
+	}
+	if fileEnabled && redisEnabled {
+		return Config{}, errors.New("file and redis storage cannot both be enabled")
+	}
+
+	filePath := "scmsh.log"
+	if path := os.Getenv(ScmshFilePathKey); path != "" {
+		filePath = path
+	}
+
+	memorySnapshotPath := os.Getenv(ScmshMemorySnapshotPathKey)
+
+	memorySnapshotInterval := 5 * time.Minute
+	if interval := os.Getenv(ScmshMemorySnapshotIntervalKey); interval != "" {
+		memorySnapshotInterval, err = time.ParseDuration(interval)
+		if err != nil {
+			return Config{}, errors.Wrapf(err, "invalid memory snapshot interval value %q", interval)
+		}
+	}
+
+	openGameTTL := 24 * time.Hour
+	if ttl := os.Getenv(ScmshOpenGameTTLKey); ttl != "" {
+		openGameTTL, err = time.ParseDuration(ttl)
+		if err != nil {
+			return Config{}, errors.Wrapf(err, "invalid open game ttl value %q", ttl)
+		}
+	}
+
+	finishedGameRetention := 7 * 24 * time.Hour
+	if retention := os.Getenv(ScmshFinishedGameRetentionKey); retention != "" {
+		finishedGameRetention, err = time.ParseDuration(retention)
+		if err != nil {
+			return Config{}, errors.Wrapf(err, "invalid finished game retention value %q", retention)
+		}
+	}
+
+	storageCodec := "json"
+	if codec := os.Getenv(ScmshStorageCodecKey); codec != "" {
+		storageCodec = codec
+	}
+
+	cacheSize := 0
+	if size := os.Getenv(ScmshCacheSizeKey); size != "" {
+		cacheSize, err = strconv.Atoi(size)
+		if err != nil {
+			return Config{}, errors.Wrapf(err, "invalid cache size value %q", size)
+		}
+	}
+
+	cacheTTL := 5 * time.Minute
+	if ttl := os.Getenv(ScmshCacheTTLKey); ttl != "" {
+		cacheTTL, err = time.ParseDuration(ttl)
+		if err != nil {
+			return Config{}, errors.Wrapf(err, "invalid cache ttl value %q", ttl)
+		}
+	}
+
 	return Config{
-		Port:          port,
-		RedisEnabled:  redisEnabled,
-		RedisAddress:  redisAddress,
-		RedisPassword: redisPassword,
-		RedisDatabase: redisDatabase,
+		Port:                   port,
+		AdminPort:              adminPort,
+		RedisEnabled:           redisEnabled,
+		RedisAddress:           redisAddress,
+		RedisPassword:          redisPassword,
+		RedisDatabase:          redisDatabase,
+		FileEnabled:            fileEnabled,
+		FilePath:               filePath,
+		MemorySnapshotPath:     memorySnapshotPath,
+		MemorySnapshotInterval: memorySnapshotInterval,
+		OpenGameTTL:            openGameTTL,
+		FinishedGameRetention:  finishedGameRetention,
+		StorageCodec:           storageCodec,
+		CacheSize:              cacheSize,
+		CacheTTL:               cacheTTL,
 	}, nil
 }
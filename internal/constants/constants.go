@@ -3,6 +3,7 @@ package constants
 type CardType int
 type PlayerStatus int
 type GameStatus int
+type Outcome int
 
 const (
 	CardTypeDagger     CardType = 1
@@ -38,6 +39,11 @@ const (
 	GameStatusOpen    = 1
 	GameStatusStarted = 2
 	GameStatusDone    = 3
+
+	OutcomeAttackerWins  Outcome = 1
+	OutcomeDefenderWins  Outcome = 2
+	OutcomeTie           Outcome = 3
+	OutcomeBothDestroyed Outcome = 4
 )
 
 var ValidCards = []CardType{
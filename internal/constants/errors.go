@@ -3,19 +3,29 @@ package constants
 import "errors"
 
 var (
+	ErrorConflict            = errors.New("conflicting write")
 	ErrorEmptyStack          = errors.New("empty stack")
+	ErrorGameAlreadyStarted  = errors.New("game already started")
+	ErrorGameOver            = errors.New("game over")
 	ErrorGameNotFound        = errors.New("game not found")
 	ErrorGameNotStarted      = errors.New("game not started")
 	ErrorIllegalMove         = errors.New("illegal move")
-	ErrorInvalidDeckCounts   = errors.New("invalid deck counts, must be five groups of five cards")
+	ErrorInvalidDeckCounts   = errors.New("invalid deck counts")
 	ErrorInvalidCardCount    = errors.New("invalid card count")
+	ErrorInvalidCardType     = errors.New("invalid card type")
+	ErrorInvalidRuleset      = errors.New("invalid ruleset")
 	ErrorInvalidStack        = errors.New("invalid stack")
+	ErrorNotEnoughPlayers    = errors.New("not enough players")
 	ErrorNotFound            = errors.New("not found")
+	ErrorPlayerAlreadyJoined = errors.New("player already joined")
 	ErrorPlayerInvalidID     = errors.New("invalid player id")
 	ErrorPlayerInvalidSecret = errors.New("invalid player secret")
 	ErrorPlayerInvalidName   = errors.New("player invalid name")
 	ErrorPlayerInvalid       = errors.New("invalid user")
+	ErrorPlayerNotAccepted   = errors.New("player not accepted")
 	ErrorPlayerNotFound      = errors.New("player not found")
 	ErrorPlayerNotOwner      = errors.New("player not owner")
+	ErrorPlayerNotRequested  = errors.New("player not requested")
+	ErrorPlayersNotReady     = errors.New("players not ready")
 	ErrorPlayerWrongTurn     = errors.New("wrong player turn")
 )
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+)
+
+type EventType string
+
+const (
+	EventGameCreated      EventType = "game_created"
+	EventRulesetChanged   EventType = "ruleset_changed"
+	EventPlayerRequested  EventType = "player_requested"
+	EventPlayerAccepted   EventType = "player_accepted"
+	EventPlayerRejected   EventType = "player_rejected"
+	EventDeckSubmitted    EventType = "deck_submitted"
+	EventPlayerReady      EventType = "player_ready"
+	EventPlayerLeft       EventType = "player_left"
+	EventGameStarted      EventType = "game_started"
+	EventMoveExecuted     EventType = "move_executed"
+	EventPlayerEliminated EventType = "player_eliminated"
+	EventGameFinished     EventType = "game_finished"
+)
+
+// Event describes something that happened to a game. Game is the game as it
+// was saved, Player is the player the event is about, if any, and Move is set
+// for EventMoveExecuted.
+type Event struct {
+	Type   EventType
+	Game   Game
+	Player uuid.UUID
+	Move   *Move
+}
+
+type Subscriber interface {
+	HandleEvent(ctx context.Context, e Event)
+}
+
+type SubscriberFunc func(ctx context.Context, e Event)
+
+func (f SubscriberFunc) HandleEvent(ctx context.Context, e Event) {
+	f(ctx, e)
+}
+
+// EventBus delivers game events to its subscribers in the order they were
+// published. Subscribers may subscribe and unsubscribe from HandleEvent.
+type EventBus struct {
+	m       sync.RWMutex
+	subs    map[*subscription]struct{}
+	dropped atomic.Int64
+}
+
+type subscription struct {
+	sub    Subscriber
+	events chan Event
+	once   sync.Once
+	// m guards events against being closed while an event is sent to it.
+	m      sync.Mutex
+	closed bool
+}
+
+// Subscribe delivers events to s synchronously, on the publisher's goroutine.
+// It returns a function that cancels the subscription.
+func (b *EventBus) Subscribe(s Subscriber) func() {
+	return b.add(&subscription{sub: s})
+}
+
+// SubscribeAsync delivers events to s on its own goroutine, so a slow
+// subscriber never holds up the publisher. Events are dropped for s while
+// its buffer is full, and counted in Dropped.
+func (b *EventBus) SubscribeAsync(s Subscriber, buffer int) func() {
+	sub := &subscription{
+		sub:    s,
+		events: make(chan Event, buffer),
+	}
+	go func() {
+		for e := range sub.events {
+			s.HandleEvent(context.Background(), e)
+		}
+	}()
+
+	return b.add(sub)
+}
+
+// Publish delivers the events to everybody subscribed when it is called.
+func (b *EventBus) Publish(ctx context.Context, events ...Event) {
+	b.m.RLock()
+	subs := make([]*subscription, 0, len(b.subs))
+	for sub := range b.subs {
+		subs = append(subs, sub)
+	}
+	b.m.RUnlock()
+
+	for _, e := range events {
+		for _, sub := range subs {
+			if sub.events == nil {
+				sub.sub.HandleEvent(ctx, e)
+				continue
+			}
+			if !sub.send(e) {
+				b.dropped.Add(1)
+			}
+		}
+	}
+}
+
+// Dropped returns how many events async subscribers have missed because
+// their buffers were full.
+func (b *EventBus) Dropped() int64 {
+	return b.dropped.Load()
+}
+
+// send queues e for an async subscriber. It reports false if the buffer is
+// full. Events for a cancelled subscription are discarded.
+func (s *subscription) send(e Event) bool {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.closed {
+		return true
+	}
+	select {
+	case s.events <- e:
+		return true
+	default:
+		return false
+	}
+}
+
+func (b *EventBus) add(sub *subscription) func() {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	b.subs[sub] = struct{}{}
+
+	return func() {
+		sub.once.Do(func() {
+			b.m.Lock()
+			delete(b.subs, sub)
+			b.m.Unlock()
+
+			if sub.events != nil {
+				sub.m.Lock()
+				defer sub.m.Unlock()
+				sub.closed = true
+				close(sub.events)
+			}
+		})
+	}
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subs: map[*subscription]struct{}{},
+	}
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventBus_Subscribe(t *testing.T) {
+	ctx := context.Background()
+	bus := NewEventBus()
+
+	var got []EventType
+	cancel := bus.Subscribe(SubscriberFunc(func(_ context.Context, e Event) {
+		got = append(got, e.Type)
+	}))
+
+	bus.Publish(ctx, Event{Type: EventGameCreated}, Event{Type: EventPlayerRequested})
+	cancel()
+	cancel()
+	bus.Publish(ctx, Event{Type: EventPlayerAccepted})
+
+	assert.Equal(t, []EventType{EventGameCreated, EventPlayerRequested}, got)
+}
+
+func TestEventBus_SubscribeAsync(t *testing.T) {
+	ctx := context.Background()
+	bus := NewEventBus()
+
+	received := make(chan Event)
+	cancel := bus.SubscribeAsync(SubscriberFunc(func(_ context.Context, e Event) {
+		received <- e
+	}), 1)
+	defer cancel()
+
+	bus.Publish(ctx, Event{Type: EventGameStarted})
+
+	select {
+	case e := <-received:
+		assert.Equal(t, EventGameStarted, e.Type)
+	case <-time.After(time.Second):
+		require.Fail(t, "event was not delivered")
+	}
+}
+
+func TestEventBus_SubscribeAsync_dropped(t *testing.T) {
+	ctx := context.Background()
+	bus := NewEventBus()
+
+	release := make(chan struct{})
+	cancel := bus.SubscribeAsync(SubscriberFunc(func(_ context.Context, e Event) {
+		<-release
+	}), 1)
+	defer cancel()
+	defer close(release)
+
+	// The first is picked up and blocks the subscriber, the second fills the
+	// buffer and the rest have nowhere to go.
+	bus.Publish(ctx, Event{Type: EventGameCreated})
+	assert.Eventually(t, func() bool {
+		bus.Publish(ctx, Event{Type: EventPlayerRequested})
+		return bus.Dropped() > 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestEventBus_Subscribe_fromHandler(t *testing.T) {
+	ctx := context.Background()
+	bus := NewEventBus()
+
+	var got []EventType
+	var cancelInner func()
+	cancel := bus.Subscribe(SubscriberFunc(func(_ context.Context, e Event) {
+		if cancelInner == nil {
+			cancelInner = bus.Subscribe(SubscriberFunc(func(_ context.Context, e Event) {
+				got = append(got, e.Type)
+			}))
+		}
+	}))
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		bus.Publish(ctx, Event{Type: EventGameCreated})
+		bus.Publish(ctx, Event{Type: EventGameStarted})
+		cancelInner()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		require.Fail(t, "publish deadlocked")
+	}
+	assert.Equal(t, []EventType{EventGameStarted}, got)
+}
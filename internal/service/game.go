@@ -1,6 +1,7 @@
 package service
 
 import (
+	"slices"
 	"strings"
 	"time"
 
@@ -15,36 +16,59 @@ type Game struct {
 	Owner         uuid.UUID
 	Players       []Player
 	Status        constants.GameStatus
+	Turn          int
+	Ruleset       *Ruleset
+	Eliminated    []uuid.UUID
+	Standings     []uuid.UUID
 	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	FinishedAt    time.Time
 }
 
-func (g *Game) ExecuteMove(playerID uuid.UUID, playerCardPosition int, targetID uuid.UUID, targetCardPosition int) (Move, error) {
-	if g.Status != constants.GameStatusStarted {
-		return Move{}, constants.ErrorGameNotStarted
+// Start begins an open game once every accepted player is ready. Players
+// still waiting on a join request are dropped.
+func (g *Game) Start() error {
+	if err := g.checkOpen(); err != nil {
+		return err
 	}
-	if playerID != g.CurrentPlayer {
-		return Move{}, constants.ErrorPlayerWrongTurn
+	for _, p := range g.Players {
+		if p.Status == constants.PlayerStatusAccepted {
+			return constants.ErrorPlayersNotReady
+		}
 	}
 
-	p, err := g.GetPlayer(playerID)
-	if err != nil {
-		return Move{}, err
+	players := slices.DeleteFunc(slices.Clone(g.Players), func(p Player) bool {
+		return p.Status == constants.PlayerStatusRequested
+	})
+	started := *g
+	started.Players = players
+	if err := started.startTurns(); err != nil {
+		return err
 	}
-	pc, err := p.GetCard(playerCardPosition)
-	if err != nil {
+	started.Status = constants.GameStatusStarted
+	*g = started
+
+	return nil
+}
+
+func (g *Game) ExecuteMove(playerID uuid.UUID, playerCardPosition int, targetID uuid.UUID, targetCardPosition int) (Move, error) {
+	if err := g.checkMove(playerID, playerCardPosition, targetID, targetCardPosition); err != nil {
 		return Move{}, err
 	}
 
-	t, err := g.GetPlayer(targetID)
+	p, err := g.GetPlayer(playerID)
 	if err != nil {
 		return Move{}, err
 	}
-	tc, err := t.GetCard(targetCardPosition)
+	t, err := g.GetPlayer(targetID)
 	if err != nil {
 		return Move{}, err
 	}
+	pc, _ := p.GetCard(playerCardPosition)
+	tc, _ := t.GetCard(targetCardPosition)
 
 	m := Move{
+		Turn:                     g.Turn,
 		Player:                   playerID,
 		PlayerCardPosition:       playerCardPosition,
 		PlayerCardType:           pc,
@@ -53,31 +77,133 @@ func (g *Game) ExecuteMove(playerID uuid.UUID, playerCardPosition int, targetID
 		TargetPlayerCardType:     tc,
 	}
 
-	winner, err := DetermineMoveWinner(m)
+	outcome, err := g.GetRuleset().Resolve(pc, tc)
 	if err != nil {
 		return Move{}, err
 	}
 
-	m.Winner = winner
+	m.Outcome = outcome
+	m.Winner = m.winnerFor(outcome)
 
-	// remove card(s) from losers
-	// handle a win
+	attackerDestroyed := outcome == constants.OutcomeDefenderWins || outcome == constants.OutcomeBothDestroyed
+	defenderDestroyed := outcome == constants.OutcomeAttackerWins || outcome == constants.OutcomeBothDestroyed
+	if attackerDestroyed {
+		if err = p.RemoveCard(playerCardPosition); err != nil {
+			return Move{}, err
+		}
+	}
+	if defenderDestroyed {
+		if err = t.RemoveCard(targetCardPosition); err != nil {
+			return Move{}, err
+		}
+	}
+
+	// Losing a Crown is only fatal once it was the last one, so rulesets
+	// can hand out more than one.
+	crownLost := map[uuid.UUID]bool{
+		playerID: attackerDestroyed && pc == constants.CardTypeCrown,
+		targetID: defenderDestroyed && tc == constants.CardTypeCrown,
+	}
+	for _, pl := range []*Player{&p, &t} {
+		if (crownLost[pl.ID] && !pl.HasCard(constants.CardTypeCrown)) || !pl.HasCards() {
+			g.eliminate(pl)
+			m.Eliminated = append(m.Eliminated, pl.ID)
+		}
+	}
+
+	if err = g.updatePlayer(p); err != nil {
+		return Move{}, err
+	}
+	if err = g.updatePlayer(t); err != nil {
+		return Move{}, err
+	}
 
 	g.Moves = append(g.Moves, m)
+	if !g.finishIfDecided() {
+		g.advanceTurn()
+	}
 
 	return m, nil
 }
 
+// GetRuleset returns the ruleset the game is played with, falling back to the
+// built-in one for games that never set their own.
+func (g *Game) GetRuleset() *Ruleset {
+	if g.Ruleset == nil {
+		return &defaultRuleset
+	}
+
+	return g.Ruleset
+}
+
+func (g *Game) eliminate(p *Player) {
+	p.Status = constants.PlayerStatusLost
+	g.Eliminated = append(g.Eliminated, p.ID)
+}
+
+// finishIfDecided ends the game once at most one player is still in it. The
+// standings list the winner first, followed by the eliminated players with
+// the most recently eliminated ranked highest.
+func (g *Game) finishIfDecided() bool {
+	active := g.ActivePlayers()
+	if len(active) > 1 {
+		return false
+	}
+
+	var standings []uuid.UUID
+	for _, id := range active {
+		for i := range g.Players {
+			if g.Players[i].ID == id {
+				g.Players[i].Status = constants.PlayerStatusWon
+			}
+		}
+		standings = append(standings, id)
+	}
+	for i := len(g.Eliminated) - 1; i >= 0; i-- {
+		standings = append(standings, g.Eliminated[i])
+	}
+
+	g.Standings = standings
+	g.Status = constants.GameStatusDone
+	g.CurrentPlayer = uuid.Nil
+	g.FinishedAt = time.Now().UTC()
+
+	return true
+}
+
 func (g *Game) GetPlayer(id uuid.UUID) (Player, error) {
 	for _, p := range g.Players {
 		if p.ID == id {
-			return p, nil
+			return copyPlayer(p), nil
 		}
 	}
 
 	return Player{}, constants.ErrorPlayerNotFound
 }
 
+func (g *Game) updatePlayer(p Player) error {
+	for i := range g.Players {
+		if g.Players[i].ID == p.ID {
+			g.Players[i] = p
+			return nil
+		}
+	}
+
+	return constants.ErrorPlayerNotFound
+}
+
+func (g *Game) Authenticate(playerID uuid.UUID, secret uuid.UUID) error {
+	p, err := g.GetPlayer(playerID)
+	if err != nil {
+		return err
+	}
+	if !p.CheckSecret(secret) {
+		return constants.ErrorPlayerInvalidSecret
+	}
+
+	return nil
+}
+
 func (g *Game) IsOwner(id uuid.UUID) bool {
 	return g.Owner == id
 }
@@ -87,10 +213,21 @@ func CreateGame(owner Player) (Game, error) {
 	if err := owner.Validate(); err != nil {
 		return Game{}, err
 	}
+	if err := owner.HashSecret(); err != nil {
+		return Game{}, err
+	}
 	owner.Status = constants.PlayerStatusAccepted
+	owner.Games = nil
+
+	// Version 7 UUIDs start with a timestamp, so games sort in the order they
+	// were created.
+	id, err := uuid.NewV7()
+	if err != nil {
+		return Game{}, err
+	}
 
 	return Game{
-		ID:            uuid.New(),
+		ID:            id,
 		CurrentPlayer: uuid.Nil,
 		Owner:         owner.ID,
 		Players:       []Player{owner},
@@ -100,188 +237,25 @@ func CreateGame(owner Player) (Game, error) {
 }
 
 type Move struct {
+	Turn                     int
 	Player                   uuid.UUID
 	PlayerCardPosition       int
 	PlayerCardType           constants.CardType
 	TargetPlayer             uuid.UUID
 	TargetPlayerCardPosition int
 	TargetPlayerCardType     constants.CardType
+	Outcome                  constants.Outcome
 	Winner                   uuid.UUID
+	Eliminated               []uuid.UUID
 }
 
-func DetermineMoveWinner(m Move) (uuid.UUID, error) {
-	switch m.PlayerCardType {
-	case constants.CardTypeDagger:
-		switch m.TargetPlayerCardType {
-		case constants.CardTypeDagger:
-			return uuid.Nil, nil
-		case constants.CardTypeShortSword:
-			return m.TargetPlayer, nil
-		case constants.CardTypeMace:
-			return m.TargetPlayer, nil
-		case constants.CardTypeBattleAxe:
-			return m.TargetPlayer, nil
-		case constants.CardTypeSpear:
-			return m.TargetPlayer, nil
-		case constants.CardTypeLongSword:
-			return m.TargetPlayer, nil
-		case constants.CardTypeArcher:
-			return m.Player, nil
-		case constants.CardTypeShield:
-			return uuid.Nil, nil
-		case constants.CardTypeCrown:
-			return m.Player, nil
-		}
-	case constants.CardTypeShortSword:
-		switch m.TargetPlayerCardType {
-		case constants.CardTypeDagger:
-			return m.Player, nil
-		case constants.CardTypeShortSword:
-			return uuid.Nil, nil
-		case constants.CardTypeMace:
-			return m.TargetPlayer, nil
-		case constants.CardTypeBattleAxe:
-			return m.TargetPlayer, nil
-		case constants.CardTypeSpear:
-			return m.TargetPlayer, nil
-		case constants.CardTypeLongSword:
-			return m.TargetPlayer, nil
-		case constants.CardTypeArcher:
-			return m.Player, nil
-		case constants.CardTypeShield:
-			return uuid.Nil, nil
-		case constants.CardTypeCrown:
-			return m.Player, nil
-		}
-	case constants.CardTypeMace:
-		switch m.TargetPlayerCardType {
-		case constants.CardTypeDagger:
-			return m.Player, nil
-		case constants.CardTypeShortSword:
-			return m.Player, nil
-		case constants.CardTypeMace:
-			return uuid.Nil, nil
-		case constants.CardTypeBattleAxe:
-			return m.TargetPlayer, nil
-		case constants.CardTypeSpear:
-			return m.TargetPlayer, nil
-		case constants.CardTypeLongSword:
-			return m.TargetPlayer, nil
-		case constants.CardTypeArcher:
-			return m.Player, nil
-		case constants.CardTypeShield:
-			return uuid.Nil, nil
-		case constants.CardTypeCrown:
-			return m.Player, nil
-		}
-	case constants.CardTypeBattleAxe:
-		switch m.TargetPlayerCardType {
-		case constants.CardTypeDagger:
-			return m.Player, nil
-		case constants.CardTypeShortSword:
-			return m.Player, nil
-		case constants.CardTypeMace:
-			return m.Player, nil
-		case constants.CardTypeBattleAxe:
-			return uuid.Nil, nil
-		case constants.CardTypeSpear:
-			return m.TargetPlayer, nil
-		case constants.CardTypeLongSword:
-			return m.TargetPlayer, nil
-		case constants.CardTypeArcher:
-			return m.Player, nil
-		case constants.CardTypeShield:
-			return uuid.Nil, nil
-		case constants.CardTypeCrown:
-			return m.Player, nil
-		}
-	case constants.CardTypeSpear:
-		switch m.TargetPlayerCardType {
-		case constants.CardTypeDagger:
-			return m.Player, nil
-		case constants.CardTypeShortSword:
-			return m.Player, nil
-		case constants.CardTypeMace:
-			return m.Player, nil
-		case constants.CardTypeBattleAxe:
-			return m.Player, nil
-		case constants.CardTypeSpear:
-			return uuid.Nil, nil
-		case constants.CardTypeLongSword:
-			return m.TargetPlayer, nil
-		case constants.CardTypeArcher:
-			return m.Player, nil
-		case constants.CardTypeShield:
-			return uuid.Nil, nil
-		case constants.CardTypeCrown:
-			return m.Player, nil
-		}
-	case constants.CardTypeLongSword:
-		switch m.TargetPlayerCardType {
-		case constants.CardTypeDagger:
-			return m.Player, nil
-		case constants.CardTypeShortSword:
-			return m.Player, nil
-		case constants.CardTypeMace:
-			return m.Player, nil
-		case constants.CardTypeBattleAxe:
-			return m.Player, nil
-		case constants.CardTypeSpear:
-			return m.Player, nil
-		case constants.CardTypeLongSword:
-			return uuid.Nil, nil
-		case constants.CardTypeArcher:
-			return m.Player, nil
-		case constants.CardTypeShield:
-			return uuid.Nil, nil
-		case constants.CardTypeCrown:
-			return m.Player, nil
-		}
-	case constants.CardTypeArcher:
-		switch m.TargetPlayerCardType {
-		case constants.CardTypeDagger:
-			return m.Player, nil
-		case constants.CardTypeShortSword:
-			return m.Player, nil
-		case constants.CardTypeMace:
-			return m.Player, nil
-		case constants.CardTypeBattleAxe:
-			return m.Player, nil
-		case constants.CardTypeSpear:
-			return m.Player, nil
-		case constants.CardTypeLongSword:
-			return m.Player, nil
-		case constants.CardTypeArcher:
-			return m.Player, nil
-		case constants.CardTypeShield:
-			return m.Player, nil
-		case constants.CardTypeCrown:
-			return m.Player, nil
-		}
-	case constants.CardTypeShield:
-		return m.TargetPlayer, nil
-	case constants.CardTypeCrown:
-		switch m.TargetPlayerCardType {
-		case constants.CardTypeDagger:
-			return m.TargetPlayer, nil
-		case constants.CardTypeShortSword:
-			return m.TargetPlayer, nil
-		case constants.CardTypeMace:
-			return m.TargetPlayer, nil
-		case constants.CardTypeBattleAxe:
-			return m.TargetPlayer, nil
-		case constants.CardTypeSpear:
-			return m.TargetPlayer, nil
-		case constants.CardTypeLongSword:
-			return m.TargetPlayer, nil
-		case constants.CardTypeArcher:
-			return m.Player, nil
-		case constants.CardTypeShield:
-			return uuid.Nil, nil
-		case constants.CardTypeCrown:
-			return m.Player, nil
-		}
+func (m *Move) winnerFor(outcome constants.Outcome) uuid.UUID {
+	switch outcome {
+	case constants.OutcomeAttackerWins:
+		return m.Player
+	case constants.OutcomeDefenderWins:
+		return m.TargetPlayer
 	}
 
-	return uuid.Nil, constants.ErrorIllegalMove
+	return uuid.Nil
 }
@@ -23,6 +23,7 @@ func TestGame_ExecuteMove(t *testing.T) {
 		targetID           uuid.UUID
 		targetCardPosition int
 		expected           Move
+		expectedDecks      map[uuid.UUID][][]constants.CardType
 		expectedError      error
 	}{
 		{
@@ -46,20 +47,190 @@ func TestGame_ExecuteMove(t *testing.T) {
 					},
 				},
 				Status: constants.GameStatusStarted,
+				Turn:   1,
 			},
 			playerID:           playerID1,
 			playerCardPosition: 0,
 			targetID:           playerID2,
 			targetCardPosition: 1,
 			expected: Move{
+				Turn:                     1,
 				Player:                   playerID1,
 				PlayerCardPosition:       0,
 				PlayerCardType:           constants.CardTypeSpear,
 				TargetPlayer:             playerID2,
 				TargetPlayerCardPosition: 1,
 				TargetPlayerCardType:     constants.CardTypeDagger,
+				Outcome:                  constants.OutcomeAttackerWins,
 				Winner:                   playerID1,
 			},
+			expectedDecks: map[uuid.UUID][][]constants.CardType{
+				playerID1: {
+					{constants.CardTypeSpear},
+					{constants.CardTypeDagger, constants.CardTypeCrown},
+				},
+				playerID2: {
+					{constants.CardTypeMace, constants.CardTypeSpear},
+					{constants.CardTypeCrown},
+				},
+			},
+		},
+		{
+			name: "valid move - target wins",
+			game: Game{
+				CurrentPlayer: playerID1,
+				Players: []Player{
+					{
+						ID: playerID1,
+						Deck: [][]constants.CardType{
+							{constants.CardTypeDagger, constants.CardTypeSpear},
+						},
+					},
+					{
+						ID: playerID2,
+						Deck: [][]constants.CardType{
+							{constants.CardTypeMace, constants.CardTypeCrown},
+						},
+					},
+				},
+				Status: constants.GameStatusStarted,
+				Turn:   1,
+			},
+			playerID:           playerID1,
+			playerCardPosition: 0,
+			targetID:           playerID2,
+			targetCardPosition: 0,
+			expected: Move{
+				Turn:                     1,
+				Player:                   playerID1,
+				PlayerCardPosition:       0,
+				PlayerCardType:           constants.CardTypeDagger,
+				TargetPlayer:             playerID2,
+				TargetPlayerCardPosition: 0,
+				TargetPlayerCardType:     constants.CardTypeMace,
+				Outcome:                  constants.OutcomeDefenderWins,
+				Winner:                   playerID2,
+			},
+			expectedDecks: map[uuid.UUID][][]constants.CardType{
+				playerID1: {
+					{constants.CardTypeSpear},
+				},
+				playerID2: {
+					{constants.CardTypeMace, constants.CardTypeCrown},
+				},
+			},
+		},
+		{
+			name: "valid move - equal cards both removed",
+			game: Game{
+				CurrentPlayer: playerID1,
+				Players: []Player{
+					{
+						ID: playerID1,
+						Deck: [][]constants.CardType{
+							{constants.CardTypeMace, constants.CardTypeSpear},
+						},
+					},
+					{
+						ID: playerID2,
+						Deck: [][]constants.CardType{
+							{constants.CardTypeMace, constants.CardTypeCrown},
+						},
+					},
+				},
+				Status: constants.GameStatusStarted,
+				Turn:   1,
+			},
+			playerID:           playerID1,
+			playerCardPosition: 0,
+			targetID:           playerID2,
+			targetCardPosition: 0,
+			expected: Move{
+				Turn:                     1,
+				Player:                   playerID1,
+				PlayerCardPosition:       0,
+				PlayerCardType:           constants.CardTypeMace,
+				TargetPlayer:             playerID2,
+				TargetPlayerCardPosition: 0,
+				TargetPlayerCardType:     constants.CardTypeMace,
+				Outcome:                  constants.OutcomeBothDestroyed,
+				Winner:                   uuid.Nil,
+			},
+			expectedDecks: map[uuid.UUID][][]constants.CardType{
+				playerID1: {
+					{constants.CardTypeSpear},
+				},
+				playerID2: {
+					{constants.CardTypeCrown},
+				},
+			},
+		},
+		{
+			name: "valid move - shield both kept",
+			game: Game{
+				CurrentPlayer: playerID1,
+				Players: []Player{
+					{
+						ID: playerID1,
+						Deck: [][]constants.CardType{
+							{constants.CardTypeLongSword, constants.CardTypeSpear},
+						},
+					},
+					{
+						ID: playerID2,
+						Deck: [][]constants.CardType{
+							{constants.CardTypeShield, constants.CardTypeCrown},
+						},
+					},
+				},
+				Status: constants.GameStatusStarted,
+				Turn:   1,
+			},
+			playerID:           playerID1,
+			playerCardPosition: 0,
+			targetID:           playerID2,
+			targetCardPosition: 0,
+			expected: Move{
+				Turn:                     1,
+				Player:                   playerID1,
+				PlayerCardPosition:       0,
+				PlayerCardType:           constants.CardTypeLongSword,
+				TargetPlayer:             playerID2,
+				TargetPlayerCardPosition: 0,
+				TargetPlayerCardType:     constants.CardTypeShield,
+				Outcome:                  constants.OutcomeTie,
+				Winner:                   uuid.Nil,
+			},
+			expectedDecks: map[uuid.UUID][][]constants.CardType{
+				playerID1: {
+					{constants.CardTypeLongSword, constants.CardTypeSpear},
+				},
+				playerID2: {
+					{constants.CardTypeShield, constants.CardTypeCrown},
+				},
+			},
+		},
+		{
+			name: "invalid - target self",
+			game: Game{
+				CurrentPlayer: playerID1,
+				Players: []Player{
+					{
+						ID: playerID1,
+						Deck: [][]constants.CardType{
+							{constants.CardTypeSpear},
+							{constants.CardTypeDagger, constants.CardTypeCrown},
+						},
+					},
+				},
+				Status: constants.GameStatusStarted,
+				Turn:   1,
+			},
+			playerID:           playerID1,
+			playerCardPosition: 0,
+			targetID:           playerID1,
+			targetCardPosition: 1,
+			expectedError:      constants.ErrorIllegalMove,
 		},
 		{
 			name: "invalid - game not started",
@@ -110,6 +281,7 @@ func TestGame_ExecuteMove(t *testing.T) {
 					},
 				},
 				Status: constants.GameStatusStarted,
+				Turn:   1,
 			},
 			playerID:           playerID2,
 			playerCardPosition: 0,
@@ -129,6 +301,161 @@ func TestGame_ExecuteMove(t *testing.T) {
 			}
 			require.NoError(t, err)
 			assert.Equal(t, tt.expected, output)
+			assert.Equal(t, []Move{output}, tt.game.Moves)
+			assert.Equal(t, 2, tt.game.Turn)
+			assert.Equal(t, tt.targetID, tt.game.CurrentPlayer)
+			for id, deck := range tt.expectedDecks {
+				p, err := tt.game.GetPlayer(id)
+				require.NoError(t, err)
+				assert.Equal(t, deck, p.Deck)
+			}
+		})
+	}
+}
+
+func TestGame_ExecuteMove_endConditions(t *testing.T) {
+	playerID1 := uuid.New()
+	playerID2 := uuid.New()
+	playerID3 := uuid.New()
+
+	twoCrowns := DefaultRuleset()
+	twoCrowns.CardCounts[constants.CardTypeCrown] = 2
+	twoCrowns.CardCounts[constants.CardTypeDagger] = 3
+
+	tests := []struct {
+		name               string
+		game               Game
+		targetID           uuid.UUID
+		expectedEliminated []uuid.UUID
+		expectedStatus     constants.GameStatus
+		expectedStandings  []uuid.UUID
+		expectedStatuses   map[uuid.UUID]constants.PlayerStatus
+		expectedCurrent    uuid.UUID
+	}{
+		{
+			name: "crown defeated",
+			game: Game{
+				CurrentPlayer: playerID1,
+				Players: []Player{
+					{ID: playerID1, Deck: [][]constants.CardType{{constants.CardTypeDagger}}},
+					{ID: playerID2, Deck: [][]constants.CardType{{constants.CardTypeCrown}, {constants.CardTypeSpear}}},
+				},
+				Status: constants.GameStatusStarted,
+				Turn:   1,
+			},
+			targetID:           playerID2,
+			expectedEliminated: []uuid.UUID{playerID2},
+			expectedStatus:     constants.GameStatusDone,
+			expectedStandings:  []uuid.UUID{playerID1, playerID2},
+			expectedStatuses: map[uuid.UUID]constants.PlayerStatus{
+				playerID1: constants.PlayerStatusWon,
+				playerID2: constants.PlayerStatusLost,
+			},
+		},
+		{
+			name: "one of two crowns defeated",
+			game: Game{
+				CurrentPlayer: playerID1,
+				Players: []Player{
+					{ID: playerID1, Deck: [][]constants.CardType{{constants.CardTypeDagger}}},
+					{ID: playerID2, Deck: [][]constants.CardType{{constants.CardTypeCrown}, {constants.CardTypeCrown}}, Status: constants.PlayerStatusAccepted},
+				},
+				Ruleset: &twoCrowns,
+				Status:  constants.GameStatusStarted,
+				Turn:    1,
+			},
+			targetID:       playerID2,
+			expectedStatus: constants.GameStatusStarted,
+			expectedStatuses: map[uuid.UUID]constants.PlayerStatus{
+				playerID2: constants.PlayerStatusAccepted,
+			},
+			expectedCurrent: playerID2,
+		},
+		{
+			name: "out of cards",
+			game: Game{
+				CurrentPlayer: playerID1,
+				Players: []Player{
+					{ID: playerID1, Deck: [][]constants.CardType{{constants.CardTypeSpear}}},
+					{ID: playerID2, Deck: [][]constants.CardType{{constants.CardTypeDagger}, {}}},
+				},
+				Status: constants.GameStatusStarted,
+				Turn:   1,
+			},
+			targetID:           playerID2,
+			expectedEliminated: []uuid.UUID{playerID2},
+			expectedStatus:     constants.GameStatusDone,
+			expectedStandings:  []uuid.UUID{playerID1, playerID2},
+			expectedStatuses: map[uuid.UUID]constants.PlayerStatus{
+				playerID1: constants.PlayerStatusWon,
+				playerID2: constants.PlayerStatusLost,
+			},
+		},
+		{
+			name: "eliminated with players remaining",
+			game: Game{
+				CurrentPlayer: playerID1,
+				Players: []Player{
+					{ID: playerID1, Deck: [][]constants.CardType{{constants.CardTypeSpear}}},
+					{ID: playerID2, Deck: [][]constants.CardType{{constants.CardTypeCrown}}},
+					{ID: playerID3, Deck: [][]constants.CardType{{constants.CardTypeDagger}}},
+				},
+				Status: constants.GameStatusStarted,
+				Turn:   1,
+			},
+			targetID:           playerID2,
+			expectedEliminated: []uuid.UUID{playerID2},
+			expectedStatus:     constants.GameStatusStarted,
+			expectedStatuses: map[uuid.UUID]constants.PlayerStatus{
+				playerID2: constants.PlayerStatusLost,
+			},
+			expectedCurrent: playerID3,
+		},
+		{
+			name: "both eliminated",
+			game: Game{
+				CurrentPlayer: playerID1,
+				Players: []Player{
+					{ID: playerID1, Deck: [][]constants.CardType{{constants.CardTypeMace}}},
+					{ID: playerID2, Deck: [][]constants.CardType{{constants.CardTypeMace}}},
+				},
+				Status: constants.GameStatusStarted,
+				Turn:   1,
+			},
+			targetID:           playerID2,
+			expectedEliminated: []uuid.UUID{playerID1, playerID2},
+			expectedStatus:     constants.GameStatusDone,
+			expectedStandings:  []uuid.UUID{playerID2, playerID1},
+			expectedStatuses: map[uuid.UUID]constants.PlayerStatus{
+				playerID1: constants.PlayerStatusLost,
+				playerID2: constants.PlayerStatusLost,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := tt.game.ExecuteMove(playerID1, 0, tt.targetID, 0)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedEliminated, output.Eliminated)
+			assert.Equal(t, tt.expectedStatus, tt.game.Status)
+			assert.Equal(t, tt.expectedStandings, tt.game.Standings)
+			for id, status := range tt.expectedStatuses {
+				p, err := tt.game.GetPlayer(id)
+				require.NoError(t, err)
+				assert.Equal(t, status, p.Status)
+			}
+
+			if tt.expectedStatus == constants.GameStatusDone {
+				assert.NotEmpty(t, tt.game.FinishedAt)
+				assert.Equal(t, uuid.Nil, tt.game.CurrentPlayer)
+
+				_, err = tt.game.ExecuteMove(playerID1, 0, tt.targetID, 0)
+				assert.ErrorIs(t, err, constants.ErrorGameOver)
+			} else {
+				assert.Empty(t, tt.game.FinishedAt)
+				assert.Equal(t, tt.expectedCurrent, tt.game.CurrentPlayer)
+			}
 		})
 	}
 }
@@ -203,7 +530,6 @@ func TestCreateGame(t *testing.T) {
 					Player{
 						ID:     validPlayer.ID,
 						Name:   validPlayer.Name,
-						Secret: validPlayer.Secret,
 						Status: constants.PlayerStatusAccepted,
 					},
 				},
@@ -248,10 +574,13 @@ func TestCreateGame(t *testing.T) {
 				return
 			}
 			require.NoError(t, err)
-			assert.NotEmpty(t, output.ID)
+			assert.Equal(t, uuid.Version(7), output.ID.Version())
 			output.ID = uuid.Nil
 			assert.NotEmpty(t, output.CreatedAt)
 			output.CreatedAt = time.Time{}
+			assert.NoError(t, output.Authenticate(tt.player.ID, tt.player.Secret))
+			output.Players[0].SecretSalt = nil
+			output.Players[0].SecretHash = nil
 			assert.Equal(t, tt.expected, output)
 		})
 	}
@@ -1,13 +1,550 @@
 package service
 
-import "github.com/rBurgett/scmsh/internal/storage"
+import (
+	"context"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/oklog/ulid/v2"
+	"github.com/pkg/errors"
+	"github.com/rBurgett/scmsh/internal/constants"
+	"github.com/rBurgett/scmsh/internal/storage"
+)
+
+const (
+	GamesNamespace        = "games"
+	GamesArchiveNamespace = "games_archive"
+
+	// updateAttempts is how many times an update is tried when the game keeps
+	// changing underneath it before giving up with constants.ErrorConflict.
+	updateAttempts = 5
+
+	gameIndexStatus = "status"
+	gameIndexPlayer = "player"
+)
 
 type GameManager struct {
-	storageClient storage.Client[Game]
+	storageClient     storage.Client[Game]
+	archive           *storage.Client[Game]
+	profiles          *storage.Client[Player]
+	bus               *EventBus
+	openGameTTL       time.Duration
+	finishedRetention time.Duration
+}
+
+type GameManagerOption func(m *GameManager)
+
+// WithEventBus makes the GameManager publish to bus after every change it
+// saves.
+func WithEventBus(bus *EventBus) GameManagerOption {
+	return func(m *GameManager) {
+		m.bus = bus
+	}
+}
+
+// WithOpenGameTTL makes open games expire once nobody has touched them for
+// ttl.
+func WithOpenGameTTL(ttl time.Duration) GameManagerOption {
+	return func(m *GameManager) {
+		m.openGameTTL = ttl
+	}
+}
+
+// WithFinishedGameRetention makes finished games expire retention after they
+// finish. Set up an archive to keep them around after that.
+func WithFinishedGameRetention(retention time.Duration) GameManagerOption {
+	return func(m *GameManager) {
+		m.finishedRetention = retention
+	}
+}
+
+// WithArchive makes the GameManager copy every game that finishes into
+// client, where it is kept for good. GetGame falls back to the archive.
+func WithArchive(client storage.Client[Game]) GameManagerOption {
+	return func(m *GameManager) {
+		m.archive = &client
+	}
+}
+
+// WithPlayerProfiles makes the GameManager add every game that starts to the
+// profiles of its players in client, in the same transaction as the game.
+func WithPlayerProfiles(client storage.Client[Player]) GameManagerOption {
+	return func(m *GameManager) {
+		m.profiles = &client
+	}
+}
+
+func (m *GameManager) CreateGame(ctx context.Context, owner Player) (Game, error) {
+	g, err := CreateGame(owner)
+	if err != nil {
+		return Game{}, err
+	}
+
+	err = m.storageClient.UpsertOne(ctx, g.ID, g)
+	if err != nil {
+		return Game{}, err
+	}
+	m.publish(ctx, Event{Type: EventGameCreated, Game: g, Player: g.Owner})
+
+	return g, nil
+}
+
+func (m *GameManager) GetGame(ctx context.Context, id uuid.UUID) (Game, error) {
+	g, err := m.storageClient.FindOne(ctx, id)
+	if errors.Is(err, constants.ErrorNotFound) && m.archive != nil {
+		g, err = m.archive.FindOne(ctx, id)
+	}
+	if err != nil {
+		if errors.Is(err, constants.ErrorNotFound) {
+			return Game{}, constants.ErrorGameNotFound
+		}
+		return Game{}, err
+	}
+
+	return g, nil
+}
+
+// GetView returns the game as the given player is allowed to see it. Anybody
+// who is not in the game, including uuid.Nil, gets the spectator view.
+func (m *GameManager) GetView(ctx context.Context, gameID uuid.UUID, viewerID uuid.UUID, secret uuid.UUID) (GameView, error) {
+	g, err := m.GetGame(ctx, gameID)
+	if err != nil {
+		return GameView{}, err
+	}
+	if _, err = g.GetPlayer(viewerID); err != nil {
+		return g.SpectatorView(), nil
+	}
+	if err = g.Authenticate(viewerID, secret); err != nil {
+		return GameView{}, err
+	}
+
+	return g.ViewFor(viewerID), nil
+}
+
+func (m *GameManager) ListGames(ctx context.Context) ([]Game, error) {
+	return m.storageClient.FindAll(ctx)
+}
+
+// ListOpenGames returns the games still waiting for players, oldest first.
+func (m *GameManager) ListOpenGames(ctx context.Context) ([]Game, error) {
+	return m.storageClient.FindIndex(ctx, gameIndexStatus, strconv.Itoa(constants.GameStatusOpen), storage.MinScore, storage.MaxScore)
+}
+
+// ListGamesForPlayer returns the games the player is in, oldest first.
+func (m *GameManager) ListGamesForPlayer(ctx context.Context, playerID uuid.UUID) ([]Game, error) {
+	return m.storageClient.FindIndex(ctx, gameIndexPlayer, playerID.String(), storage.MinScore, storage.MaxScore)
+}
+
+// ListFinishedSince returns the games that finished at or after since, in the
+// order they finished.
+func (m *GameManager) ListFinishedSince(ctx context.Context, since time.Time) ([]Game, error) {
+	return m.storageClient.FindIndex(ctx, gameIndexStatus, strconv.Itoa(constants.GameStatusDone), float64(since.UnixMilli()), storage.MaxScore)
+}
+
+// Reindex saves the games written before the indexes existed again, so the
+// indexes pick them up, moving games still stored under their legacy key to
+// their own ID on the way. Games that are indexed already are left alone, so
+// once everything has been moved there is nothing left to do.
+func (m *GameManager) Reindex(ctx context.Context) error {
+	games, err := m.storageClient.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	indexed := map[uuid.UUID]bool{}
+	for _, status := range []int{constants.GameStatusOpen, constants.GameStatusStarted, constants.GameStatusDone} {
+		found, err := m.storageClient.FindIndex(ctx, gameIndexStatus, strconv.Itoa(status), storage.MinScore, storage.MaxScore)
+		if err != nil {
+			return err
+		}
+		for _, g := range found {
+			indexed[g.ID] = true
+		}
+	}
+
+	// A game that shows up twice is under both keys, so the legacy copy is
+	// still there to be removed.
+	seen := map[uuid.UUID]bool{}
+	for _, g := range games {
+		if indexed[g.ID] && !seen[g.ID] {
+			seen[g.ID] = true
+			continue
+		}
+		if err = m.reindex(ctx, g.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reindex saves the game again under its own ID, checking it has not changed
+// in the meantime, and deletes its legacy copy. A game under both keys keeps
+// the copy under its own ID. A game somebody else changes first is skipped,
+// since their write indexed it.
+func (m *GameManager) reindex(ctx context.Context, id uuid.UUID) error {
+	tx := storage.NewTx()
+
+	g, version, err := m.storageClient.FindOneVersioned(ctx, id)
+	current := err == nil
+	if err != nil && !errors.Is(err, constants.ErrorNotFound) {
+		return err
+	}
+	if current {
+		if err = m.storageClient.TxCompareAndSwap(tx, id, version, g); err != nil {
+			return err
+		}
+	}
+
+	legacy, legacyVersion, err := m.storageClient.FindOneVersioned(ctx, legacyKey(id))
+	switch {
+	case errors.Is(err, constants.ErrorNotFound):
+		if !current {
+			return nil
+		}
+	case err != nil:
+		return err
+	default:
+		if !current {
+			// Version 0 only matches a record that does not exist.
+			if err = m.storageClient.TxCompareAndSwap(tx, id, 0, legacy); err != nil {
+				return err
+			}
+		}
+		if err = m.storageClient.TxCompareAndDelete(tx, legacyKey(id), legacyVersion); err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if errors.Is(err, constants.ErrorConflict) {
+		return nil
+	}
+
+	return err
+}
+
+func (m *GameManager) RequestJoin(ctx context.Context, gameID uuid.UUID, p Player) (Game, error) {
+	g, err := m.update(ctx, gameID, func(g *Game) error {
+		return g.RequestJoin(p)
+	})
+	if err != nil {
+		return Game{}, err
+	}
+	m.publish(ctx, Event{Type: EventPlayerRequested, Game: g, Player: p.ID})
+
+	return g, nil
+}
+
+func (m *GameManager) Accept(ctx context.Context, gameID uuid.UUID, ownerID uuid.UUID, secret uuid.UUID, playerID uuid.UUID) (Game, error) {
+	g, err := m.updateAs(ctx, gameID, ownerID, secret, func(g *Game) error {
+		return g.Accept(ownerID, playerID)
+	})
+	if err != nil {
+		return Game{}, err
+	}
+	m.publish(ctx, Event{Type: EventPlayerAccepted, Game: g, Player: playerID})
+
+	return g, nil
+}
+
+func (m *GameManager) Reject(ctx context.Context, gameID uuid.UUID, ownerID uuid.UUID, secret uuid.UUID, playerID uuid.UUID) (Game, error) {
+	g, err := m.updateAs(ctx, gameID, ownerID, secret, func(g *Game) error {
+		return g.Reject(ownerID, playerID)
+	})
+	if err != nil {
+		return Game{}, err
+	}
+	m.publish(ctx, Event{Type: EventPlayerRejected, Game: g, Player: playerID})
+
+	return g, nil
+}
+
+func (m *GameManager) SetRuleset(ctx context.Context, gameID uuid.UUID, ownerID uuid.UUID, secret uuid.UUID, r Ruleset) (Game, error) {
+	g, err := m.updateAs(ctx, gameID, ownerID, secret, func(g *Game) error {
+		return g.SetRuleset(ownerID, r)
+	})
+	if err != nil {
+		return Game{}, err
+	}
+	m.publish(ctx, Event{Type: EventRulesetChanged, Game: g, Player: ownerID})
+
+	return g, nil
+}
+
+func (m *GameManager) SubmitDeck(ctx context.Context, gameID uuid.UUID, playerID uuid.UUID, secret uuid.UUID, deck [][]constants.CardType) (Game, error) {
+	g, err := m.updateAs(ctx, gameID, playerID, secret, func(g *Game) error {
+		return g.SubmitDeck(playerID, deck)
+	})
+	if err != nil {
+		return Game{}, err
+	}
+	m.publish(ctx, Event{Type: EventDeckSubmitted, Game: g, Player: playerID})
+
+	return g, nil
+}
+
+func (m *GameManager) SetReady(ctx context.Context, gameID uuid.UUID, playerID uuid.UUID, secret uuid.UUID) (Game, error) {
+	g, err := m.updateAs(ctx, gameID, playerID, secret, func(g *Game) error {
+		return g.SetReady(playerID)
+	})
+	if err != nil {
+		return Game{}, err
+	}
+	m.publish(ctx, Event{Type: EventPlayerReady, Game: g, Player: playerID})
+
+	return g, nil
+}
+
+// Leave removes the player from the game. An open game that no longer has
+// anybody to own it is deleted.
+func (m *GameManager) Leave(ctx context.Context, gameID uuid.UUID, playerID uuid.UUID, secret uuid.UUID) (Game, error) {
+	var wasActive bool
+	g, err := m.updateAs(ctx, gameID, playerID, secret, func(g *Game) error {
+		wasActive = g.Status == constants.GameStatusStarted && slices.Contains(g.ActivePlayers(), playerID)
+		return g.Leave(playerID)
+	})
+	if err != nil {
+		return Game{}, err
+	}
+
+	events := []Event{{Type: EventPlayerLeft, Game: g, Player: playerID}}
+	if wasActive {
+		events = append(events, Event{Type: EventPlayerEliminated, Game: g, Player: playerID})
+	}
+	m.publish(ctx, append(events, finishedEvents(g)...)...)
+
+	return g, nil
+}
+
+func (m *GameManager) StartGame(ctx context.Context, gameID uuid.UUID, ownerID uuid.UUID, secret uuid.UUID) (Game, error) {
+	g, err := m.updateAs(ctx, gameID, ownerID, secret, func(g *Game) error {
+		if !g.IsOwner(ownerID) {
+			return constants.ErrorPlayerNotOwner
+		}
+		return g.Start()
+	})
+	if err != nil {
+		return Game{}, err
+	}
+	m.publish(ctx, Event{Type: EventGameStarted, Game: g, Player: ownerID})
+
+	return g, nil
+}
+
+func (m *GameManager) LegalMoves(ctx context.Context, gameID uuid.UUID, playerID uuid.UUID, secret uuid.UUID) ([]LegalMove, error) {
+	g, err := m.GetGame(ctx, gameID)
+	if err != nil {
+		return nil, err
+	}
+	if err = g.Authenticate(playerID, secret); err != nil {
+		return nil, err
+	}
+
+	return g.LegalMoves(playerID)
+}
+
+func (m *GameManager) ExecuteMove(ctx context.Context, gameID uuid.UUID, playerID uuid.UUID, secret uuid.UUID, playerCardPosition int, targetID uuid.UUID, targetCardPosition int) (Move, error) {
+	var move Move
+	g, err := m.updateAs(ctx, gameID, playerID, secret, func(g *Game) (err error) {
+		move, err = g.ExecuteMove(playerID, playerCardPosition, targetID, targetCardPosition)
+		return err
+	})
+	if err != nil {
+		return Move{}, err
+	}
+
+	events := []Event{{Type: EventMoveExecuted, Game: g, Player: playerID, Move: &move}}
+	for _, id := range move.Eliminated {
+		events = append(events, Event{Type: EventPlayerEliminated, Game: g, Player: id})
+	}
+	m.publish(ctx, append(events, finishedEvents(g)...)...)
+
+	return move, nil
+}
+
+// update loads the game, applies fn to it and saves the result, deleting the
+// game instead if it was abandoned. Nothing is saved if fn fails. If the game
+// is changed by somebody else in the meantime, fn is run again on the fresh
+// copy. Everything the change entails, such as archiving the game or adding
+// it to the profiles of its players, is saved in the same transaction.
+func (m *GameManager) update(ctx context.Context, id uuid.UUID, fn func(g *Game) error) (Game, error) {
+	for range updateAttempts {
+		g, version, err := m.storageClient.FindOneVersioned(ctx, id)
+		if err != nil {
+			if errors.Is(err, constants.ErrorNotFound) {
+				return Game{}, constants.ErrorGameNotFound
+			}
+			return Game{}, err
+		}
+
+		status := g.Status
+		if err = fn(&g); err != nil {
+			return Game{}, err
+		}
+		g.UpdatedAt = time.Now().UTC()
+
+		tx := storage.NewTx()
+		if g.IsAbandoned() {
+			err = m.storageClient.TxCompareAndDelete(tx, g.ID, version)
+		} else {
+			err = m.storageClient.TxCompareAndSwap(tx, g.ID, version, g)
+		}
+		if err != nil {
+			return Game{}, err
+		}
+		if status != constants.GameStatusDone && g.Status == constants.GameStatusDone && m.archive != nil {
+			if err = m.archive.TxUpsert(tx, g.ID, g); err != nil {
+				return Game{}, errors.Wrap(err, "archive game")
+			}
+		}
+		if status == constants.GameStatusOpen && g.Status == constants.GameStatusStarted {
+			if err = m.addToProfiles(ctx, tx, g); err != nil {
+				return Game{}, err
+			}
+		}
+
+		err = tx.Commit(ctx)
+		if errors.Is(err, constants.ErrorConflict) {
+			continue
+		}
+		if err != nil {
+			return Game{}, err
+		}
+
+		return g, nil
+	}
+
+	return Game{}, constants.ErrorConflict
 }
 
-func NewGameManager(client storage.Client[Game]) *GameManager {
-	return &GameManager{
-		storageClient: client,
+// addToProfiles adds the game to the profile of each of its players, checking
+// the profiles have not changed by the time tx is committed. Profiles still
+// under their legacy key are updated there. Players without a stored profile
+// are skipped.
+func (m *GameManager) addToProfiles(ctx context.Context, tx *storage.Tx, g Game) error {
+	if m.profiles == nil {
+		return nil
+	}
+
+	for _, p := range g.Players {
+		var key storage.ID = p.ID
+		profile, version, err := m.profiles.FindOneVersioned(ctx, key)
+		if errors.Is(err, constants.ErrorNotFound) {
+			key = legacyKey(p.ID)
+			profile, version, err = m.profiles.FindOneVersioned(ctx, key)
+		}
+		if errors.Is(err, constants.ErrorNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		profile.Games = append(profile.Games, g.ID)
+		if err = m.profiles.TxCompareAndSwap(tx, key, version, profile); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// updateAs is update for actions taken by a player, who has to prove who they
+// are with their secret first.
+func (m *GameManager) updateAs(ctx context.Context, id uuid.UUID, playerID uuid.UUID, secret uuid.UUID, fn func(g *Game) error) (Game, error) {
+	return m.update(ctx, id, func(g *Game) error {
+		if err := g.Authenticate(playerID, secret); err != nil {
+			return err
+		}
+		return fn(g)
+	})
+}
+
+func (m *GameManager) publish(ctx context.Context, events ...Event) {
+	if m.bus == nil {
+		return
+	}
+	m.bus.Publish(ctx, events...)
+}
+
+// finishedEvents returns EventGameFinished, about the winner if there is one,
+// once the game is over.
+func finishedEvents(g Game) []Event {
+	if g.Status != constants.GameStatusDone {
+		return nil
+	}
+
+	var winner uuid.UUID
+	for _, p := range g.Players {
+		if p.Status == constants.PlayerStatusWon {
+			winner = p.ID
+		}
 	}
+
+	return []Event{{Type: EventGameFinished, Game: g, Player: winner}}
+}
+
+// gameTTL is how much longer a game is kept. Open games are kept for a while
+// after they were last changed and finished games for a while after they
+// finished, so saving a game again without changing it, as Reindex does, does
+// not start its TTL over.
+func (m *GameManager) gameTTL(g Game) time.Duration {
+	var ttl time.Duration
+	var since time.Time
+	switch g.Status {
+	case constants.GameStatusOpen:
+		ttl, since = m.openGameTTL, g.UpdatedAt
+		if since.IsZero() {
+			since = g.CreatedAt
+		}
+	case constants.GameStatusDone:
+		ttl, since = m.finishedRetention, g.FinishedAt
+	default:
+		return 0
+	}
+	if ttl <= 0 || since.IsZero() {
+		return ttl
+	}
+
+	// A TTL of 0 would keep the game forever, so one that has run out gets the
+	// shortest TTL Redis takes instead.
+	return max(ttl-time.Since(since), time.Millisecond)
+}
+
+// indexGame files a game under its status and under each of its players.
+// Finished games are ordered by when they finished, everything else by when it
+// was created.
+func indexGame(g Game) []storage.IndexEntry {
+	score := float64(g.CreatedAt.UnixMilli())
+	statusScore := score
+	if g.Status == constants.GameStatusDone {
+		statusScore = float64(g.FinishedAt.UnixMilli())
+	}
+
+	res := []storage.IndexEntry{{Index: gameIndexStatus, Value: strconv.Itoa(int(g.Status)), Score: statusScore}}
+	for _, p := range g.Players {
+		res = append(res, storage.IndexEntry{Index: gameIndexPlayer, Value: p.ID.String(), Score: score})
+	}
+
+	return res
+}
+
+// legacyKey is the key records used to be stored under, when storage could
+// only be keyed by ULIDs. UUIDs and ULIDs are both 128 bits, so it was the
+// same ID in a different spelling.
+func legacyKey(id uuid.UUID) ulid.ULID {
+	return ulid.ULID(id)
+}
+
+func NewGameManager(client storage.Client[Game], opts ...GameManagerOption) *GameManager {
+	m := &GameManager{}
+	for _, opt := range opts {
+		opt(m)
+	}
+	m.storageClient = *client.WithIndexers(indexGame).WithTTL(m.gameTTL)
+
+	return m
 }
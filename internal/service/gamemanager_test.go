@@ -0,0 +1,558 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/rBurgett/scmsh/internal/config"
+	"github.com/rBurgett/scmsh/internal/constants"
+	"github.com/rBurgett/scmsh/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestGameManager(t *testing.T) *GameManager {
+	s := miniredis.RunT(t)
+	driver := storage.NewRedisDriver(config.Config{
+		RedisAddress: s.Addr(),
+	})
+
+	return NewGameManager(*storage.NewClient[Game](driver, GamesNamespace))
+}
+
+func TestGameManager_lobby(t *testing.T) {
+	ctx := context.Background()
+	m := newTestGameManager(t)
+
+	owner := newTestPlayer(t, "Ryan")
+	joiner := newTestPlayer(t, "Isaac")
+
+	g, err := m.CreateGame(ctx, owner)
+	require.NoError(t, err)
+
+	stored, err := m.GetGame(ctx, g.ID)
+	require.NoError(t, err)
+	assert.Equal(t, g.ID, stored.ID)
+
+	_, err = m.RequestJoin(ctx, g.ID, joiner)
+	require.NoError(t, err)
+
+	_, err = m.Accept(ctx, g.ID, joiner.ID, joiner.Secret, joiner.ID)
+	assert.ErrorIs(t, err, constants.ErrorPlayerNotOwner)
+
+	_, err = m.Accept(ctx, g.ID, owner.ID, owner.Secret, joiner.ID)
+	require.NoError(t, err)
+
+	_, err = m.SetReady(ctx, g.ID, owner.ID, uuid.New())
+	assert.ErrorIs(t, err, constants.ErrorPlayerInvalidSecret)
+
+	_, err = m.SetReady(ctx, g.ID, owner.ID, owner.Secret)
+	assert.ErrorIs(t, err, constants.ErrorInvalidDeckCounts)
+
+	_, err = m.SubmitDeck(ctx, g.ID, owner.ID, owner.Secret, newTestDeck())
+	require.NoError(t, err)
+
+	_, err = m.SubmitDeck(ctx, g.ID, joiner.ID, joiner.Secret, newTestDeck())
+	require.NoError(t, err)
+
+	_, err = m.SetReady(ctx, g.ID, owner.ID, owner.Secret)
+	require.NoError(t, err)
+
+	_, err = m.StartGame(ctx, g.ID, owner.ID, owner.Secret)
+	assert.ErrorIs(t, err, constants.ErrorPlayersNotReady)
+
+	_, err = m.SetReady(ctx, g.ID, joiner.ID, joiner.Secret)
+	require.NoError(t, err)
+
+	_, err = m.StartGame(ctx, g.ID, joiner.ID, joiner.Secret)
+	assert.ErrorIs(t, err, constants.ErrorPlayerNotOwner)
+
+	_, err = m.StartGame(ctx, g.ID, owner.ID, owner.Secret)
+	require.NoError(t, err)
+
+	_, err = m.ExecuteMove(ctx, g.ID, owner.ID, joiner.Secret, 0, joiner.ID, 0)
+	assert.ErrorIs(t, err, constants.ErrorPlayerInvalidSecret)
+
+	legal, err := m.LegalMoves(ctx, g.ID, owner.ID, owner.Secret)
+	require.NoError(t, err)
+	assert.NotEmpty(t, legal)
+
+	move, err := m.ExecuteMove(ctx, g.ID, owner.ID, owner.Secret, 0, joiner.ID, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, move.Turn)
+
+	stored, err = m.GetGame(ctx, g.ID)
+	require.NoError(t, err)
+	assert.Equal(t, constants.GameStatus(constants.GameStatusStarted), stored.Status)
+	assert.Equal(t, 2, stored.Turn)
+	assert.Equal(t, joiner.ID, stored.CurrentPlayer)
+	assert.Len(t, stored.Moves, 1)
+	for _, p := range stored.Players {
+		assert.Equal(t, uuid.Nil, p.Secret)
+		assert.NotEmpty(t, p.SecretHash)
+	}
+
+	view, err := m.GetView(ctx, g.ID, joiner.ID, joiner.Secret)
+	require.NoError(t, err)
+	assert.Equal(t, joiner.ID, view.Viewer)
+
+	_, err = m.GetView(ctx, g.ID, joiner.ID, owner.Secret)
+	assert.ErrorIs(t, err, constants.ErrorPlayerInvalidSecret)
+
+	view, err = m.GetView(ctx, g.ID, uuid.Nil, uuid.Nil)
+	require.NoError(t, err)
+	assert.Equal(t, uuid.Nil, view.Viewer)
+}
+
+func TestGameManager_Leave(t *testing.T) {
+	ctx := context.Background()
+	m := newTestGameManager(t)
+
+	owner := newTestPlayer(t, "Ryan")
+
+	g, err := m.CreateGame(ctx, owner)
+	require.NoError(t, err)
+
+	_, err = m.Leave(ctx, g.ID, owner.ID, uuid.New())
+	assert.ErrorIs(t, err, constants.ErrorPlayerInvalidSecret)
+
+	_, err = m.Leave(ctx, g.ID, owner.ID, owner.Secret)
+	require.NoError(t, err)
+
+	_, err = m.GetGame(ctx, g.ID)
+	assert.ErrorIs(t, err, constants.ErrorGameNotFound)
+}
+
+func TestGameManager_GetGame(t *testing.T) {
+	ctx := context.Background()
+	m := newTestGameManager(t)
+
+	_, err := m.GetGame(ctx, uuid.New())
+	assert.ErrorIs(t, err, constants.ErrorGameNotFound)
+}
+
+func TestGameManager_events(t *testing.T) {
+	ctx := context.Background()
+	bus := NewEventBus()
+	var events []Event
+	bus.Subscribe(SubscriberFunc(func(_ context.Context, e Event) {
+		events = append(events, e)
+	}))
+
+	driver := storage.NewMemDriver()
+	m := NewGameManager(*storage.NewClient[Game](driver, GamesNamespace), WithEventBus(bus))
+
+	owner := newTestPlayer(t, "Ryan")
+	joiner := newTestPlayer(t, "Isaac")
+
+	g, err := m.CreateGame(ctx, owner)
+	require.NoError(t, err)
+	_, err = m.RequestJoin(ctx, g.ID, joiner)
+	require.NoError(t, err)
+	_, err = m.Accept(ctx, g.ID, owner.ID, owner.Secret, joiner.ID)
+	require.NoError(t, err)
+	for _, p := range []Player{owner, joiner} {
+		_, err = m.SubmitDeck(ctx, g.ID, p.ID, p.Secret, newTestDeck())
+		require.NoError(t, err)
+		_, err = m.SetReady(ctx, g.ID, p.ID, p.Secret)
+		require.NoError(t, err)
+	}
+
+	_, err = m.StartGame(ctx, g.ID, joiner.ID, joiner.Secret)
+	require.ErrorIs(t, err, constants.ErrorPlayerNotOwner)
+
+	_, err = m.StartGame(ctx, g.ID, owner.ID, owner.Secret)
+	require.NoError(t, err)
+	_, err = m.Leave(ctx, g.ID, joiner.ID, joiner.Secret)
+	require.NoError(t, err)
+
+	var types []EventType
+	for _, e := range events {
+		assert.Equal(t, g.ID, e.Game.ID)
+		types = append(types, e.Type)
+	}
+	assert.Equal(t, []EventType{
+		EventGameCreated,
+		EventPlayerRequested,
+		EventPlayerAccepted,
+		EventDeckSubmitted,
+		EventPlayerReady,
+		EventDeckSubmitted,
+		EventPlayerReady,
+		EventGameStarted,
+		EventPlayerLeft,
+		EventPlayerEliminated,
+		EventGameFinished,
+	}, types)
+
+	last := events[len(events)-1]
+	assert.Equal(t, owner.ID, last.Player)
+	assert.Equal(t, constants.GameStatus(constants.GameStatusDone), last.Game.Status)
+}
+
+func TestGameManager_concurrentUpdates(t *testing.T) {
+	ctx := context.Background()
+	m := newTestGameManager(t)
+
+	g, err := m.CreateGame(ctx, newTestPlayer(t, "Ryan"))
+	require.NoError(t, err)
+
+	joiners := []Player{
+		newTestPlayer(t, "Isaac"),
+		newTestPlayer(t, "Abby"),
+		newTestPlayer(t, "Josh"),
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(joiners))
+	for i, p := range joiners {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = m.RequestJoin(ctx, g.ID, p)
+		}()
+	}
+	wg.Wait()
+
+	g, err = m.GetGame(ctx, g.ID)
+	require.NoError(t, err)
+	for i, p := range joiners {
+		if errs[i] != nil {
+			assert.ErrorIs(t, errs[i], constants.ErrorConflict)
+			continue
+		}
+		_, err = g.GetPlayer(p.ID)
+		assert.NoError(t, err, "lost the join of %s", p.Name)
+	}
+}
+
+func TestGameManager_queries(t *testing.T) {
+	ctx := context.Background()
+	m := newTestGameManager(t)
+
+	owner := newTestPlayer(t, "Ryan")
+	joiner := newTestPlayer(t, "Isaac")
+
+	open, err := m.CreateGame(ctx, owner)
+	require.NoError(t, err)
+
+	played, err := m.CreateGame(ctx, owner)
+	require.NoError(t, err)
+	_, err = m.RequestJoin(ctx, played.ID, joiner)
+	require.NoError(t, err)
+	_, err = m.Accept(ctx, played.ID, owner.ID, owner.Secret, joiner.ID)
+	require.NoError(t, err)
+	for _, p := range []Player{owner, joiner} {
+		_, err = m.SubmitDeck(ctx, played.ID, p.ID, p.Secret, newTestDeck())
+		require.NoError(t, err)
+		_, err = m.SetReady(ctx, played.ID, p.ID, p.Secret)
+		require.NoError(t, err)
+	}
+	_, err = m.StartGame(ctx, played.ID, owner.ID, owner.Secret)
+	require.NoError(t, err)
+
+	gameIDs := func(games []Game) (res []uuid.UUID) {
+		for _, g := range games {
+			res = append(res, g.ID)
+		}
+		return res
+	}
+
+	games, err := m.ListOpenGames(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{open.ID}, gameIDs(games))
+
+	games, err = m.ListGamesForPlayer(ctx, owner.ID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uuid.UUID{open.ID, played.ID}, gameIDs(games))
+
+	games, err = m.ListGamesForPlayer(ctx, joiner.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{played.ID}, gameIDs(games))
+
+	games, err = m.ListFinishedSince(ctx, time.Time{})
+	require.NoError(t, err)
+	assert.Empty(t, games)
+
+	before := time.Now().Add(-time.Second)
+	_, err = m.Leave(ctx, played.ID, joiner.ID, joiner.Secret)
+	require.NoError(t, err)
+
+	games, err = m.ListFinishedSince(ctx, before)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{played.ID}, gameIDs(games))
+
+	games, err = m.ListFinishedSince(ctx, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, games)
+
+	_, err = m.Leave(ctx, open.ID, owner.ID, owner.Secret)
+	require.NoError(t, err)
+
+	games, err = m.ListOpenGames(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, games)
+}
+
+func TestGameManager_expiry(t *testing.T) {
+	ctx := context.Background()
+
+	s := miniredis.RunT(t)
+	driver := storage.NewRedisDriver(config.Config{
+		RedisAddress: s.Addr(),
+	})
+	m := NewGameManager(
+		*storage.NewClient[Game](driver, GamesNamespace),
+		WithOpenGameTTL(time.Hour),
+		WithFinishedGameRetention(24*time.Hour),
+		WithArchive(*storage.NewClient[Game](driver, GamesArchiveNamespace)),
+	)
+
+	owner := newTestPlayer(t, "Ryan")
+	joiner := newTestPlayer(t, "Isaac")
+
+	stale, err := m.CreateGame(ctx, owner)
+	require.NoError(t, err)
+
+	g, err := m.CreateGame(ctx, owner)
+	require.NoError(t, err)
+	_, err = m.RequestJoin(ctx, g.ID, joiner)
+	require.NoError(t, err)
+	_, err = m.Accept(ctx, g.ID, owner.ID, owner.Secret, joiner.ID)
+	require.NoError(t, err)
+	for _, p := range []Player{owner, joiner} {
+		_, err = m.SubmitDeck(ctx, g.ID, p.ID, p.Secret, newTestDeck())
+		require.NoError(t, err)
+		_, err = m.SetReady(ctx, g.ID, p.ID, p.Secret)
+		require.NoError(t, err)
+	}
+	_, err = m.StartGame(ctx, g.ID, owner.ID, owner.Secret)
+	require.NoError(t, err)
+
+	// started games never expire
+	s.FastForward(2 * time.Hour)
+
+	_, err = m.GetGame(ctx, stale.ID)
+	assert.ErrorIs(t, err, constants.ErrorGameNotFound)
+	_, err = m.GetGame(ctx, g.ID)
+	require.NoError(t, err)
+
+	_, err = m.Leave(ctx, g.ID, joiner.ID, joiner.Secret)
+	require.NoError(t, err)
+
+	s.FastForward(25 * time.Hour)
+
+	games, err := m.ListGames(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, games)
+
+	archived, err := m.GetGame(ctx, g.ID)
+	require.NoError(t, err)
+	assert.Equal(t, constants.GameStatus(constants.GameStatusDone), archived.Status)
+}
+
+func TestGameManager_gameTTL(t *testing.T) {
+	m := NewGameManager(storage.Client[Game]{},
+		WithOpenGameTTL(time.Hour),
+		WithFinishedGameRetention(24*time.Hour),
+	)
+	now := time.Now().UTC()
+
+	tests := []struct {
+		name     string
+		game     Game
+		expected time.Duration
+	}{
+		{
+			name:     "open game counts from its last change",
+			game:     Game{Status: constants.GameStatusOpen, CreatedAt: now.Add(-2 * time.Hour), UpdatedAt: now.Add(-30 * time.Minute)},
+			expected: 30 * time.Minute,
+		},
+		{
+			name:     "open game never changed counts from its creation",
+			game:     Game{Status: constants.GameStatusOpen, CreatedAt: now.Add(-45 * time.Minute)},
+			expected: 15 * time.Minute,
+		},
+		{
+			name:     "started game never expires",
+			game:     Game{Status: constants.GameStatusStarted, CreatedAt: now.Add(-2 * time.Hour)},
+			expected: 0,
+		},
+		{
+			name:     "finished game counts from when it finished",
+			game:     Game{Status: constants.GameStatusDone, UpdatedAt: now, FinishedAt: now.Add(-23 * time.Hour)},
+			expected: time.Hour,
+		},
+		{
+			name:     "finished game past its retention",
+			game:     Game{Status: constants.GameStatusDone, FinishedAt: now.Add(-25 * time.Hour)},
+			expected: time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.expected, m.gameTTL(tt.game), float64(time.Second))
+		})
+	}
+}
+
+func TestGameManager_ListGames(t *testing.T) {
+	ctx := context.Background()
+	m := newTestGameManager(t)
+
+	var expected []uuid.UUID
+	for range 5 {
+		g, err := m.CreateGame(ctx, newTestPlayer(t, "Ryan"))
+		require.NoError(t, err)
+		expected = append(expected, g.ID)
+	}
+
+	games, err := m.ListGames(ctx)
+	require.NoError(t, err)
+
+	var output []uuid.UUID
+	for _, g := range games {
+		output = append(output, g.ID)
+	}
+	assert.Equal(t, expected, output)
+}
+
+func TestGameManager_Reindex(t *testing.T) {
+	ctx := context.Background()
+	client := storage.NewClient[Game](storage.NewMemDriver(), GamesNamespace)
+	m := NewGameManager(*client)
+
+	g, err := CreateGame(newTestPlayer(t, "Ryan"))
+	require.NoError(t, err)
+	require.NoError(t, client.UpsertOne(ctx, legacyKey(g.ID), g))
+
+	_, err = m.GetGame(ctx, g.ID)
+	require.ErrorIs(t, err, constants.ErrorGameNotFound)
+
+	require.NoError(t, m.Reindex(ctx))
+
+	_, err = m.GetGame(ctx, g.ID)
+	require.NoError(t, err)
+
+	games, err := m.ListOpenGames(ctx)
+	require.NoError(t, err)
+	assert.Len(t, games, 1)
+
+	games, err = m.ListGames(ctx)
+	require.NoError(t, err)
+	assert.Len(t, games, 1)
+	_, err = client.FindOne(ctx, legacyKey(g.ID))
+	assert.ErrorIs(t, err, constants.ErrorNotFound)
+
+	// Games that are indexed already are not written again.
+	_, version, err := client.FindOneVersioned(ctx, g.ID)
+	require.NoError(t, err)
+	require.NoError(t, m.Reindex(ctx))
+	_, reindexed, err := client.FindOneVersioned(ctx, g.ID)
+	require.NoError(t, err)
+	assert.Equal(t, version, reindexed)
+
+	// A game under both keys keeps the copy under its own ID.
+	stale := g
+	stale.Status = constants.GameStatusDone
+	require.NoError(t, client.UpsertOne(ctx, legacyKey(g.ID), stale))
+	require.NoError(t, m.Reindex(ctx))
+
+	stored, err := m.GetGame(ctx, g.ID)
+	require.NoError(t, err)
+	assert.Equal(t, constants.GameStatus(constants.GameStatusOpen), stored.Status)
+	_, err = client.FindOne(ctx, legacyKey(g.ID))
+	assert.ErrorIs(t, err, constants.ErrorNotFound)
+}
+
+func TestGameManager_codecs(t *testing.T) {
+	for _, codec := range []storage.Codec{storage.JSONCodec, storage.GobCodec, storage.GzipJSONCodec} {
+		t.Run(codec.Name(), func(t *testing.T) {
+			ctx := context.Background()
+			m := NewGameManager(*storage.NewClient[Game](storage.NewMemDriver(), GamesNamespace, storage.WithCodec(codec)))
+
+			owner := newTestPlayer(t, "Ryan")
+			g, err := m.CreateGame(ctx, owner)
+			require.NoError(t, err)
+
+			g, err = m.SetRuleset(ctx, g.ID, owner.ID, owner.Secret, DefaultRuleset())
+			require.NoError(t, err)
+
+			stored, err := m.GetGame(ctx, g.ID)
+			require.NoError(t, err)
+			assert.Equal(t, g.ID, stored.ID)
+			assert.Equal(t, g.Ruleset, stored.Ruleset)
+			assert.True(t, g.CreatedAt.Equal(stored.CreatedAt))
+		})
+	}
+}
+
+func TestGameManager_playerProfiles(t *testing.T) {
+	ctx := context.Background()
+	s := miniredis.RunT(t)
+	driver := storage.NewRedisDriver(config.Config{
+		RedisAddress: s.Addr(),
+	})
+	profiles := storage.NewClient[Player](driver, PlayersNamespace)
+	players := NewPlayerManager(*profiles)
+	m := NewGameManager(*storage.NewClient[Game](driver, GamesNamespace), WithPlayerProfiles(*profiles))
+
+	created, err := players.CreatePlayer(ctx, "Ryan")
+	require.NoError(t, err)
+	owner, err := players.Authenticate(ctx, created.ID, created.Secret)
+	require.NoError(t, err)
+	// A player from before records were keyed by their own IDs.
+	joiner := newTestPlayer(t, "Isaac")
+	require.NoError(t, profiles.UpsertOne(ctx, legacyKey(joiner.ID), joiner))
+	// Players without a stored profile are left alone.
+	guest := newTestPlayer(t, "Kim")
+
+	var gameIDs []uuid.UUID
+	for range 2 {
+		g, err := m.CreateGame(ctx, owner)
+		require.NoError(t, err)
+		for _, p := range []Player{joiner, guest} {
+			_, err = m.RequestJoin(ctx, g.ID, p)
+			require.NoError(t, err)
+			_, err = m.Accept(ctx, g.ID, owner.ID, owner.Secret, p.ID)
+			require.NoError(t, err)
+		}
+		for _, p := range []Player{owner, joiner, guest} {
+			_, err = m.SubmitDeck(ctx, g.ID, p.ID, p.Secret, newTestDeck())
+			require.NoError(t, err)
+			_, err = m.SetReady(ctx, g.ID, p.ID, p.Secret)
+			require.NoError(t, err)
+		}
+
+		profile, err := players.GetPlayer(ctx, owner.ID)
+		require.NoError(t, err)
+		assert.Equal(t, gameIDs, profile.Games)
+
+		g, err = m.StartGame(ctx, g.ID, owner.ID, owner.Secret)
+		require.NoError(t, err)
+		gameIDs = append(gameIDs, g.ID)
+
+		profile, err = players.GetPlayer(ctx, owner.ID)
+		require.NoError(t, err)
+		assert.Equal(t, gameIDs, profile.Games)
+		for _, p := range g.Players {
+			assert.Empty(t, p.Games)
+		}
+		owner = profile
+		owner.Secret = created.Secret
+	}
+
+	profile, err := players.GetPlayer(ctx, joiner.ID)
+	require.NoError(t, err)
+	assert.Equal(t, gameIDs, profile.Games)
+	_, err = profiles.FindOne(ctx, joiner.ID)
+	assert.ErrorIs(t, err, constants.ErrorNotFound)
+
+	_, err = players.GetPlayer(ctx, guest.ID)
+	assert.ErrorIs(t, err, constants.ErrorPlayerNotFound)
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/rBurgett/scmsh/internal/constants"
+)
+
+func (g *Game) RequestJoin(p Player) error {
+	if err := g.checkOpen(); err != nil {
+		return err
+	}
+
+	p.Name = strings.TrimSpace(p.Name)
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	if _, err := g.GetPlayer(p.ID); err == nil {
+		return constants.ErrorPlayerAlreadyJoined
+	}
+
+	if err := p.HashSecret(); err != nil {
+		return err
+	}
+	p.Deck = nil
+	p.Games = nil
+	p.Status = constants.PlayerStatusRequested
+	g.Players = append(g.Players, p)
+
+	return nil
+}
+
+func (g *Game) Accept(ownerID uuid.UUID, playerID uuid.UUID) error {
+	p, err := g.getRequestedPlayer(ownerID, playerID)
+	if err != nil {
+		return err
+	}
+
+	p.Status = constants.PlayerStatusAccepted
+
+	return g.updatePlayer(p)
+}
+
+func (g *Game) Reject(ownerID uuid.UUID, playerID uuid.UUID) error {
+	p, err := g.getRequestedPlayer(ownerID, playerID)
+	if err != nil {
+		return err
+	}
+
+	g.removePlayer(p.ID)
+
+	return nil
+}
+
+func (g *Game) SetRuleset(ownerID uuid.UUID, r Ruleset) error {
+	if err := g.checkOpen(); err != nil {
+		return err
+	}
+	if !g.IsOwner(ownerID) {
+		return constants.ErrorPlayerNotOwner
+	}
+	if err := r.Validate(); err != nil {
+		return err
+	}
+
+	r = copyRuleset(r)
+	g.Ruleset = &r
+
+	// Decks were checked against the previous card inventory and stack
+	// shape, so everybody has to submit again.
+	for i := range g.Players {
+		g.Players[i].Deck = nil
+		if g.Players[i].Status == constants.PlayerStatusReady {
+			g.Players[i].Status = constants.PlayerStatusAccepted
+		}
+	}
+
+	return nil
+}
+
+// SubmitDeck sets the player's stack arrangement for the game. An invalid
+// deck is rejected and any previously submitted deck is kept.
+func (g *Game) SubmitDeck(playerID uuid.UUID, deck [][]constants.CardType) error {
+	p, err := g.getAcceptedPlayer(playerID)
+	if err != nil {
+		return err
+	}
+
+	p.Deck = deck
+	p = copyPlayer(p)
+	if err = p.ValidateDeck(g.GetRuleset()); err != nil {
+		return err
+	}
+
+	return g.updatePlayer(p)
+}
+
+func (g *Game) SetReady(playerID uuid.UUID) error {
+	p, err := g.getAcceptedPlayer(playerID)
+	if err != nil {
+		return err
+	}
+	if err = p.ValidateDeck(g.GetRuleset()); err != nil {
+		return err
+	}
+
+	p.Status = constants.PlayerStatusReady
+
+	return g.updatePlayer(p)
+}
+
+// Leave removes a player from an open game, handing ownership to the next
+// accepted player when the owner leaves. If nobody is left to take over, the
+// owner is cleared and the game should be discarded. Leaving a started game
+// forfeits it.
+func (g *Game) Leave(playerID uuid.UUID) error {
+	p, err := g.GetPlayer(playerID)
+	if err != nil {
+		return err
+	}
+
+	switch g.Status {
+	case constants.GameStatusOpen:
+		g.removePlayer(playerID)
+		if g.IsOwner(playerID) {
+			g.Owner = uuid.Nil
+			for _, other := range g.Players {
+				if other.Status == constants.PlayerStatusAccepted || other.Status == constants.PlayerStatusReady {
+					g.Owner = other.ID
+					break
+				}
+			}
+		}
+	case constants.GameStatusStarted:
+		if p.Status == constants.PlayerStatusLost {
+			return nil
+		}
+		g.eliminate(&p)
+		if err = g.updatePlayer(p); err != nil {
+			return err
+		}
+		if !g.finishIfDecided() && g.CurrentPlayer == playerID {
+			g.advanceTurn()
+		}
+	default:
+		return constants.ErrorGameOver
+	}
+
+	return nil
+}
+
+func (g *Game) IsAbandoned() bool {
+	return g.Owner == uuid.Nil
+}
+
+func (g *Game) checkOpen() error {
+	switch g.Status {
+	case constants.GameStatusOpen:
+		return nil
+	case constants.GameStatusDone:
+		return constants.ErrorGameOver
+	}
+
+	return constants.ErrorGameAlreadyStarted
+}
+
+func (g *Game) getRequestedPlayer(ownerID uuid.UUID, playerID uuid.UUID) (Player, error) {
+	if err := g.checkOpen(); err != nil {
+		return Player{}, err
+	}
+	if !g.IsOwner(ownerID) {
+		return Player{}, constants.ErrorPlayerNotOwner
+	}
+
+	p, err := g.GetPlayer(playerID)
+	if err != nil {
+		return Player{}, err
+	}
+	if p.Status != constants.PlayerStatusRequested {
+		return Player{}, constants.ErrorPlayerNotRequested
+	}
+
+	return p, nil
+}
+
+func (g *Game) getAcceptedPlayer(playerID uuid.UUID) (Player, error) {
+	if err := g.checkOpen(); err != nil {
+		return Player{}, err
+	}
+
+	p, err := g.GetPlayer(playerID)
+	if err != nil {
+		return Player{}, err
+	}
+	if p.Status != constants.PlayerStatusAccepted && p.Status != constants.PlayerStatusReady {
+		return Player{}, constants.ErrorPlayerNotAccepted
+	}
+
+	return p, nil
+}
+
+func (g *Game) removePlayer(id uuid.UUID) {
+	g.Players = slices.DeleteFunc(g.Players, func(p Player) bool {
+		return p.ID == id
+	})
+}
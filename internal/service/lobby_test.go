@@ -0,0 +1,258 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rBurgett/scmsh/internal/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPlayer(t *testing.T, name string) Player {
+	p, err := CreatePlayer(name)
+	require.NoError(t, err)
+
+	return p
+}
+
+func TestGame_RequestJoin(t *testing.T) {
+	owner := newTestPlayer(t, "Ryan")
+	joiner := newTestPlayer(t, "Isaac")
+
+	tests := []struct {
+		name          string
+		status        constants.GameStatus
+		player        Player
+		expectedError error
+	}{
+		{
+			name:   "valid request",
+			status: constants.GameStatusOpen,
+			player: joiner,
+		},
+		{
+			name:          "invalid - already joined",
+			status:        constants.GameStatusOpen,
+			player:        owner,
+			expectedError: constants.ErrorPlayerAlreadyJoined,
+		},
+		{
+			name:          "invalid - bad player",
+			status:        constants.GameStatusOpen,
+			player:        Player{ID: uuid.New(), Name: "  ", Secret: uuid.New()},
+			expectedError: constants.ErrorPlayerInvalidName,
+		},
+		{
+			name:          "invalid - game started",
+			status:        constants.GameStatusStarted,
+			player:        joiner,
+			expectedError: constants.ErrorGameAlreadyStarted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := CreateGame(owner)
+			require.NoError(t, err)
+			g.Status = tt.status
+
+			err = g.RequestJoin(tt.player)
+			if tt.expectedError != nil {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+
+			p, err := g.GetPlayer(tt.player.ID)
+			require.NoError(t, err)
+			assert.Equal(t, constants.PlayerStatusRequested, p.Status)
+		})
+	}
+}
+
+func TestGame_Accept(t *testing.T) {
+	owner := newTestPlayer(t, "Ryan")
+	joiner := newTestPlayer(t, "Isaac")
+
+	tests := []struct {
+		name          string
+		ownerID       uuid.UUID
+		playerID      uuid.UUID
+		expectedError error
+	}{
+		{
+			name:     "valid accept",
+			ownerID:  owner.ID,
+			playerID: joiner.ID,
+		},
+		{
+			name:          "invalid - not owner",
+			ownerID:       joiner.ID,
+			playerID:      joiner.ID,
+			expectedError: constants.ErrorPlayerNotOwner,
+		},
+		{
+			name:          "invalid - not requested",
+			ownerID:       owner.ID,
+			playerID:      owner.ID,
+			expectedError: constants.ErrorPlayerNotRequested,
+		},
+		{
+			name:          "invalid - unknown player",
+			ownerID:       owner.ID,
+			playerID:      uuid.New(),
+			expectedError: constants.ErrorPlayerNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := CreateGame(owner)
+			require.NoError(t, err)
+			require.NoError(t, g.RequestJoin(joiner))
+
+			err = g.Accept(tt.ownerID, tt.playerID)
+			if tt.expectedError != nil {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+
+			p, err := g.GetPlayer(tt.playerID)
+			require.NoError(t, err)
+			assert.Equal(t, constants.PlayerStatusAccepted, p.Status)
+		})
+	}
+}
+
+func TestGame_Reject(t *testing.T) {
+	owner := newTestPlayer(t, "Ryan")
+	joiner := newTestPlayer(t, "Isaac")
+
+	g, err := CreateGame(owner)
+	require.NoError(t, err)
+	require.NoError(t, g.RequestJoin(joiner))
+
+	err = g.Reject(joiner.ID, joiner.ID)
+	assert.ErrorIs(t, err, constants.ErrorPlayerNotOwner)
+
+	require.NoError(t, g.Reject(owner.ID, joiner.ID))
+	_, err = g.GetPlayer(joiner.ID)
+	assert.ErrorIs(t, err, constants.ErrorPlayerNotFound)
+}
+
+func TestGame_SubmitDeck(t *testing.T) {
+	owner := newTestPlayer(t, "Ryan")
+	joiner := newTestPlayer(t, "Isaac")
+
+	g, err := CreateGame(owner)
+	require.NoError(t, err)
+	require.NoError(t, g.RequestJoin(joiner))
+
+	err = g.SubmitDeck(joiner.ID, newTestDeck())
+	assert.ErrorIs(t, err, constants.ErrorPlayerNotAccepted)
+
+	require.NoError(t, g.SubmitDeck(owner.ID, newTestDeck()))
+
+	invalid := newTestDeck()
+	invalid[0] = nil
+	err = g.SubmitDeck(owner.ID, invalid)
+	assert.ErrorIs(t, err, constants.ErrorInvalidDeckCounts)
+
+	p, err := g.GetPlayer(owner.ID)
+	require.NoError(t, err)
+	assert.Equal(t, newTestDeck(), p.Deck)
+}
+
+func TestGame_SetReady(t *testing.T) {
+	owner := newTestPlayer(t, "Ryan")
+	joiner := newTestPlayer(t, "Isaac")
+
+	g, err := CreateGame(owner)
+	require.NoError(t, err)
+	require.NoError(t, g.RequestJoin(joiner))
+
+	err = g.SetReady(joiner.ID)
+	assert.ErrorIs(t, err, constants.ErrorPlayerNotAccepted)
+
+	err = g.SetReady(owner.ID)
+	assert.ErrorIs(t, err, constants.ErrorInvalidDeckCounts)
+
+	require.NoError(t, g.SubmitDeck(owner.ID, newTestDeck()))
+	require.NoError(t, g.SetReady(owner.ID))
+	p, err := g.GetPlayer(owner.ID)
+	require.NoError(t, err)
+	assert.Equal(t, constants.PlayerStatusReady, p.Status)
+}
+
+func TestGame_Leave(t *testing.T) {
+	owner := newTestPlayer(t, "Ryan")
+	joiner := newTestPlayer(t, "Isaac")
+
+	t.Run("owner hands over to accepted player", func(t *testing.T) {
+		g, err := CreateGame(owner)
+		require.NoError(t, err)
+		require.NoError(t, g.RequestJoin(joiner))
+		require.NoError(t, g.Accept(owner.ID, joiner.ID))
+
+		require.NoError(t, g.Leave(owner.ID))
+		assert.Equal(t, joiner.ID, g.Owner)
+		assert.Len(t, g.Players, 1)
+		assert.False(t, g.IsAbandoned())
+	})
+
+	t.Run("owner abandons game", func(t *testing.T) {
+		g, err := CreateGame(owner)
+		require.NoError(t, err)
+		require.NoError(t, g.RequestJoin(joiner))
+
+		require.NoError(t, g.Leave(owner.ID))
+		assert.True(t, g.IsAbandoned())
+	})
+
+	t.Run("forfeit started game", func(t *testing.T) {
+		deck := [][]constants.CardType{{constants.CardTypeDagger}}
+		g := Game{
+			CurrentPlayer: owner.ID,
+			Owner:         owner.ID,
+			Players: []Player{
+				{ID: owner.ID, Deck: deck, Status: constants.PlayerStatusReady},
+				{ID: joiner.ID, Deck: deck, Status: constants.PlayerStatusReady},
+			},
+			Status: constants.GameStatusStarted,
+			Turn:   1,
+		}
+
+		require.NoError(t, g.Leave(owner.ID))
+		assert.Equal(t, constants.GameStatus(constants.GameStatusDone), g.Status)
+		assert.Equal(t, []uuid.UUID{joiner.ID, owner.ID}, g.Standings)
+	})
+}
+
+func TestGame_Start_readiness(t *testing.T) {
+	owner := newTestPlayer(t, "Ryan")
+	joiner := newTestPlayer(t, "Isaac")
+	pending := newTestPlayer(t, "Pending")
+
+	g, err := CreateGame(owner)
+	require.NoError(t, err)
+	require.NoError(t, g.RequestJoin(joiner))
+	require.NoError(t, g.RequestJoin(pending))
+	require.NoError(t, g.Accept(owner.ID, joiner.ID))
+	require.NoError(t, g.SubmitDeck(owner.ID, newTestDeck()))
+	require.NoError(t, g.SubmitDeck(joiner.ID, newTestDeck()))
+	require.NoError(t, g.SetReady(owner.ID))
+
+	err = g.Start()
+	assert.ErrorIs(t, err, constants.ErrorPlayersNotReady)
+	assert.Equal(t, constants.GameStatus(constants.GameStatusOpen), g.Status)
+
+	require.NoError(t, g.SetReady(joiner.ID))
+	require.NoError(t, g.Start())
+	assert.Equal(t, constants.GameStatus(constants.GameStatusStarted), g.Status)
+	assert.Len(t, g.Players, 2)
+	assert.Equal(t, owner.ID, g.CurrentPlayer)
+}
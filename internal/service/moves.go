@@ -0,0 +1,91 @@
+package service
+
+import (
+	"github.com/google/uuid"
+	"github.com/rBurgett/scmsh/internal/constants"
+)
+
+type LegalMove struct {
+	PlayerCardPosition       int
+	TargetPlayer             uuid.UUID
+	TargetPlayerCardPosition int
+}
+
+// LegalMoves lists every attack the player can make right now. It applies the
+// same checks ExecuteMove does, so anything returned here will be accepted.
+func (g *Game) LegalMoves(playerID uuid.UUID) ([]LegalMove, error) {
+	if err := g.checkTurn(playerID); err != nil {
+		return nil, err
+	}
+
+	p, err := g.GetPlayer(playerID)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []LegalMove
+	for playerCardPosition := range p.Deck {
+		for _, t := range g.Players {
+			for targetCardPosition := range t.Deck {
+				if g.checkMove(playerID, playerCardPosition, t.ID, targetCardPosition) != nil {
+					continue
+				}
+				res = append(res, LegalMove{
+					PlayerCardPosition:       playerCardPosition,
+					TargetPlayer:             t.ID,
+					TargetPlayerCardPosition: targetCardPosition,
+				})
+			}
+		}
+	}
+
+	return res, nil
+}
+
+func (g *Game) IsLegalMove(playerID uuid.UUID, playerCardPosition int, targetID uuid.UUID, targetCardPosition int) bool {
+	return g.checkMove(playerID, playerCardPosition, targetID, targetCardPosition) == nil
+}
+
+func (g *Game) checkTurn(playerID uuid.UUID) error {
+	if g.Status == constants.GameStatusDone {
+		return constants.ErrorGameOver
+	}
+	if g.Status != constants.GameStatusStarted {
+		return constants.ErrorGameNotStarted
+	}
+	if playerID != g.CurrentPlayer {
+		return constants.ErrorPlayerWrongTurn
+	}
+
+	return nil
+}
+
+func (g *Game) checkMove(playerID uuid.UUID, playerCardPosition int, targetID uuid.UUID, targetCardPosition int) error {
+	if err := g.checkTurn(playerID); err != nil {
+		return err
+	}
+	if playerID == targetID {
+		return constants.ErrorIllegalMove
+	}
+
+	p, err := g.GetPlayer(playerID)
+	if err != nil {
+		return err
+	}
+	if _, err = p.GetCard(playerCardPosition); err != nil {
+		return err
+	}
+
+	t, err := g.GetPlayer(targetID)
+	if err != nil {
+		return err
+	}
+	if t.Status == constants.PlayerStatusLost {
+		return constants.ErrorIllegalMove
+	}
+	if _, err = t.GetCard(targetCardPosition); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rBurgett/scmsh/internal/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGame_LegalMoves(t *testing.T) {
+	playerID1 := uuid.New()
+	playerID2 := uuid.New()
+	playerID3 := uuid.New()
+
+	newGame := func(status constants.GameStatus) Game {
+		return Game{
+			CurrentPlayer: playerID1,
+			Players: []Player{
+				{
+					ID: playerID1,
+					Deck: [][]constants.CardType{
+						{constants.CardTypeSpear},
+						{},
+					},
+				},
+				{
+					ID: playerID2,
+					Deck: [][]constants.CardType{
+						{},
+						{constants.CardTypeDagger, constants.CardTypeCrown},
+					},
+				},
+				{
+					ID:     playerID3,
+					Deck:   [][]constants.CardType{{constants.CardTypeMace}},
+					Status: constants.PlayerStatusLost,
+				},
+			},
+			Status: status,
+		}
+	}
+
+	tests := []struct {
+		name          string
+		game          Game
+		playerID      uuid.UUID
+		expected      []LegalMove
+		expectedError error
+	}{
+		{
+			name:     "current player",
+			game:     newGame(constants.GameStatusStarted),
+			playerID: playerID1,
+			expected: []LegalMove{
+				{
+					PlayerCardPosition:       0,
+					TargetPlayer:             playerID2,
+					TargetPlayerCardPosition: 1,
+				},
+			},
+		},
+		{
+			name:          "invalid - wrong turn",
+			game:          newGame(constants.GameStatusStarted),
+			playerID:      playerID2,
+			expectedError: constants.ErrorPlayerWrongTurn,
+		},
+		{
+			name:          "invalid - not started",
+			game:          newGame(constants.GameStatusOpen),
+			playerID:      playerID1,
+			expectedError: constants.ErrorGameNotStarted,
+		},
+		{
+			name:          "invalid - game over",
+			game:          newGame(constants.GameStatusDone),
+			playerID:      playerID1,
+			expectedError: constants.ErrorGameOver,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := tt.game.LegalMoves(tt.playerID)
+			if tt.expectedError != nil {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, output)
+
+			for _, m := range output {
+				assert.True(t, tt.game.IsLegalMove(tt.playerID, m.PlayerCardPosition, m.TargetPlayer, m.TargetPlayerCardPosition))
+			}
+		})
+	}
+}
+
+func TestGame_IsLegalMove(t *testing.T) {
+	playerID1 := uuid.New()
+	playerID2 := uuid.New()
+	playerID3 := uuid.New()
+
+	g := Game{
+		CurrentPlayer: playerID1,
+		Players: []Player{
+			{ID: playerID1, Deck: [][]constants.CardType{{constants.CardTypeSpear}, {}}},
+			{ID: playerID2, Deck: [][]constants.CardType{{constants.CardTypeDagger}}},
+			{ID: playerID3, Deck: [][]constants.CardType{{constants.CardTypeMace}}, Status: constants.PlayerStatusLost},
+		},
+		Status: constants.GameStatusStarted,
+	}
+
+	tests := []struct {
+		name               string
+		playerCardPosition int
+		targetID           uuid.UUID
+		targetCardPosition int
+		expectedError      error
+	}{
+		{
+			name:     "legal",
+			targetID: playerID2,
+		},
+		{
+			name:               "invalid - empty stack",
+			playerCardPosition: 1,
+			targetID:           playerID2,
+			expectedError:      constants.ErrorEmptyStack,
+		},
+		{
+			name:               "invalid - bad target stack",
+			targetID:           playerID2,
+			targetCardPosition: 3,
+			expectedError:      constants.ErrorInvalidStack,
+		},
+		{
+			name:          "invalid - eliminated target",
+			targetID:      playerID3,
+			expectedError: constants.ErrorIllegalMove,
+		},
+		{
+			name:          "invalid - unknown target",
+			targetID:      uuid.New(),
+			expectedError: constants.ErrorPlayerNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := g.checkMove(playerID1, tt.playerCardPosition, tt.targetID, tt.targetCardPosition)
+			assert.Equal(t, tt.expectedError == nil, g.IsLegalMove(playerID1, tt.playerCardPosition, tt.targetID, tt.targetCardPosition))
+			if tt.expectedError != nil {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
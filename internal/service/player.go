@@ -1,6 +1,10 @@
 package service
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"slices"
 	"strings"
 	"unicode/utf8"
 
@@ -10,14 +14,20 @@ import (
 
 const (
 	PlayerNameMaxLength = 20
+
+	secretSaltLength = 16
 )
 
 type Player struct {
-	ID     uuid.UUID
-	Deck   [][]constants.CardType
-	Name   string
-	Secret uuid.UUID
-	Status constants.PlayerStatus
+	ID         uuid.UUID
+	Deck       [][]constants.CardType
+	Name       string
+	Secret     uuid.UUID
+	SecretSalt []byte
+	SecretHash []byte
+	Status     constants.PlayerStatus
+	// Games lists the games the player has started, on their profile only.
+	Games []uuid.UUID
 }
 
 func (p *Player) Validate() error {
@@ -34,23 +44,53 @@ func (p *Player) Validate() error {
 	return nil
 }
 
-func (p *Player) ValidateDeck() error {
+// HashSecret replaces the plaintext secret with a salted hash so that it can
+// be persisted. Use CheckSecret to verify a secret afterwards.
+func (p *Player) HashSecret() error {
+	if p.Secret == uuid.Nil {
+		return constants.ErrorPlayerInvalidSecret
+	}
+
+	salt := make([]byte, secretSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+
+	p.SecretSalt = salt
+	p.SecretHash = hashSecret(salt, p.Secret)
+	p.Secret = uuid.Nil
+
+	return nil
+}
+
+func (p *Player) CheckSecret(secret uuid.UUID) bool {
+	if len(p.SecretHash) == 0 {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare(p.SecretHash, hashSecret(p.SecretSalt, secret)) == 1
+}
+
+func (p *Player) ValidateDeck(r *Ruleset) error {
 	cardCounts := map[constants.CardType]int{}
-	if len(p.Deck) != constants.DeckCount {
+	if len(p.Deck) != r.StackCount {
 		return constants.ErrorInvalidDeckCounts
 	}
 
 	for _, cards := range p.Deck {
-		if len(cards) != constants.DeckCardCount {
+		if len(cards) != r.StackSize {
 			return constants.ErrorInvalidDeckCounts
 		}
 		for _, card := range cards {
+			if !slices.Contains(constants.ValidCards, card) {
+				return constants.ErrorInvalidCardType
+			}
 			cardCounts[card]++
 		}
 	}
 
-	for k, v := range cardCounts {
-		if v != constants.GetCardCount(k) {
+	for _, card := range constants.ValidCards {
+		if cardCounts[card] != r.GetCardCount(card) {
 			return constants.ErrorInvalidCardCount
 		}
 	}
@@ -58,6 +98,31 @@ func (p *Player) ValidateDeck() error {
 	return nil
 }
 
+func (p *Player) HasCards() bool {
+	for _, stack := range p.Deck {
+		if len(stack) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasCard reports whether any of the player's stacks still holds the card.
+func (p *Player) HasCard(cardType constants.CardType) bool {
+	for _, stack := range p.Deck {
+		if slices.Contains(stack, cardType) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *Player) IsActive() bool {
+	return p.Status != constants.PlayerStatusLost && p.HasCards()
+}
+
 func (p *Player) GetCard(position int) (constants.CardType, error) {
 	if position < 0 || position > len(p.Deck)-1 {
 		return 0, constants.ErrorInvalidStack
@@ -71,6 +136,16 @@ func (p *Player) GetCard(position int) (constants.CardType, error) {
 	return stack[0], nil
 }
 
+func (p *Player) RemoveCard(position int) error {
+	if _, err := p.GetCard(position); err != nil {
+		return err
+	}
+
+	p.Deck[position] = p.Deck[position][1:]
+
+	return nil
+}
+
 func CreatePlayer(name string) (Player, error) {
 	name = strings.TrimSpace(name)
 	if name == "" || utf8.RuneCountInString(name) > PlayerNameMaxLength {
@@ -85,6 +160,24 @@ func CreatePlayer(name string) (Player, error) {
 	}, nil
 }
 
+func hashSecret(salt []byte, secret uuid.UUID) []byte {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write(secret[:])
+
+	return h.Sum(nil)
+}
+
 func copyPlayer(p Player) Player {
+	if p.Deck == nil {
+		return p
+	}
+
+	deck := make([][]constants.CardType, len(p.Deck))
+	for i, stack := range p.Deck {
+		deck[i] = slices.Clone(stack)
+	}
+	p.Deck = deck
+
 	return p
 }
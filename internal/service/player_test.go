@@ -74,14 +74,203 @@ func TestPlayer_Validate(t *testing.T) {
 	}
 }
 
+func newTestDeck() [][]constants.CardType {
+	var cards []constants.CardType
+	for _, card := range constants.ValidCards {
+		for range constants.GetCardCount(card) {
+			cards = append(cards, card)
+		}
+	}
+
+	var deck [][]constants.CardType
+	for i := 0; i < len(cards); i += constants.DeckCardCount {
+		deck = append(deck, cards[i:i+constants.DeckCardCount])
+	}
+
+	return deck
+}
+
 func TestPlayer_ValidateDeck(t *testing.T) {
+	unknownCard := newTestDeck()
+	unknownCard[4][4] = constants.CardType(42)
+
+	missingCrown := newTestDeck()
+	missingCrown[4][4] = constants.CardTypeDagger
+
+	tooManyStacks := append(newTestDeck(), []constants.CardType{})
+
+	shortStack := newTestDeck()
+	shortStack[0] = shortStack[0][1:]
+
+	small := newSmallRuleset()
+
+	tests := []struct {
+		name          string
+		ruleset       *Ruleset
+		deck          [][]constants.CardType
+		expectedError error
+	}{
+		{
+			name: "valid deck",
+			deck: newTestDeck(),
+		},
+		{
+			name:    "valid small deck",
+			ruleset: &small,
+			deck: [][]constants.CardType{
+				{constants.CardTypeDagger, constants.CardTypeShortSword, constants.CardTypeMace},
+				{constants.CardTypeBattleAxe, constants.CardTypeSpear, constants.CardTypeLongSword},
+				{constants.CardTypeArcher, constants.CardTypeShield, constants.CardTypeCrown},
+			},
+		},
+		{
+			name:          "invalid - default deck in small ruleset",
+			ruleset:       &small,
+			deck:          newTestDeck(),
+			expectedError: constants.ErrorInvalidDeckCounts,
+		},
+		{
+			name:          "invalid - empty deck",
+			deck:          nil,
+			expectedError: constants.ErrorInvalidDeckCounts,
+		},
+		{
+			name:          "invalid - too many stacks",
+			deck:          tooManyStacks,
+			expectedError: constants.ErrorInvalidDeckCounts,
+		},
+		{
+			name:          "invalid - short stack",
+			deck:          shortStack,
+			expectedError: constants.ErrorInvalidDeckCounts,
+		},
+		{
+			name:          "invalid - unknown card type",
+			deck:          unknownCard,
+			expectedError: constants.ErrorInvalidCardType,
+		},
+		{
+			name:          "invalid - missing crown",
+			deck:          missingCrown,
+			expectedError: constants.ErrorInvalidCardCount,
+		},
+	}
 
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.ruleset == nil {
+				tt.ruleset = &defaultRuleset
+			}
+			p := Player{Deck: tt.deck}
+			err := p.ValidateDeck(tt.ruleset)
+			if tt.expectedError != nil {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
 }
 
 func TestPlayer_GetCard(t *testing.T) {
 
 }
 
+func TestPlayer_RemoveCard(t *testing.T) {
+	tests := []struct {
+		name          string
+		player        Player
+		position      int
+		expected      [][]constants.CardType
+		expectedError error
+	}{
+		{
+			name: "removes top card",
+			player: Player{
+				Deck: [][]constants.CardType{
+					{constants.CardTypeDagger},
+					{constants.CardTypeMace, constants.CardTypeCrown},
+				},
+			},
+			position: 1,
+			expected: [][]constants.CardType{
+				{constants.CardTypeDagger},
+				{constants.CardTypeCrown},
+			},
+		},
+		{
+			name: "removes last card",
+			player: Player{
+				Deck: [][]constants.CardType{
+					{constants.CardTypeDagger},
+				},
+			},
+			position: 0,
+			expected: [][]constants.CardType{
+				{},
+			},
+		},
+		{
+			name: "invalid - empty stack",
+			player: Player{
+				Deck: [][]constants.CardType{
+					{},
+				},
+			},
+			position:      0,
+			expectedError: constants.ErrorEmptyStack,
+		},
+		{
+			name: "invalid - bad position",
+			player: Player{
+				Deck: [][]constants.CardType{
+					{constants.CardTypeDagger},
+				},
+			},
+			position:      1,
+			expectedError: constants.ErrorInvalidStack,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.player.RemoveCard(tt.position)
+			if tt.expectedError != nil {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, tt.player.Deck)
+		})
+	}
+}
+
+func TestPlayer_CheckSecret(t *testing.T) {
+	secret := uuid.New()
+	p := Player{
+		ID:     uuid.New(),
+		Name:   "Ryan",
+		Secret: secret,
+	}
+	assert.False(t, p.CheckSecret(secret))
+
+	require.NoError(t, p.HashSecret())
+	assert.Equal(t, uuid.Nil, p.Secret)
+	assert.NotEmpty(t, p.SecretSalt)
+	assert.NotEmpty(t, p.SecretHash)
+	assert.True(t, p.CheckSecret(secret))
+	assert.False(t, p.CheckSecret(uuid.New()))
+
+	other := Player{Secret: secret}
+	require.NoError(t, other.HashSecret())
+	assert.NotEqual(t, p.SecretHash, other.SecretHash)
+
+	err := other.HashSecret()
+	assert.ErrorIs(t, err, constants.ErrorPlayerInvalidSecret)
+}
+
 func TestCreatePlayer(t *testing.T) {
 	tests := []struct {
 		name          string
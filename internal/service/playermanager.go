@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/rBurgett/scmsh/internal/constants"
+	"github.com/rBurgett/scmsh/internal/storage"
+)
+
+const (
+	PlayersNamespace = "players"
+)
+
+type PlayerManager struct {
+	storageClient storage.Client[Player]
+}
+
+// CreatePlayer registers a new player. The returned player carries the
+// plaintext secret, which is the only time it is available; only its hash is
+// stored.
+func (m *PlayerManager) CreatePlayer(ctx context.Context, name string) (Player, error) {
+	p, err := CreatePlayer(name)
+	if err != nil {
+		return Player{}, err
+	}
+
+	stored := p
+	if err = stored.HashSecret(); err != nil {
+		return Player{}, err
+	}
+
+	err = m.storageClient.UpsertOne(ctx, p.ID, stored)
+	if err != nil {
+		return Player{}, err
+	}
+
+	return p, nil
+}
+
+// GetPlayer looks the player up by ID, falling back to the legacy key for
+// players created before records were keyed by their own IDs.
+func (m *PlayerManager) GetPlayer(ctx context.Context, id uuid.UUID) (Player, error) {
+	p, err := m.storageClient.FindOne(ctx, id)
+	if errors.Is(err, constants.ErrorNotFound) {
+		p, err = m.storageClient.FindOne(ctx, legacyKey(id))
+	}
+	if err != nil {
+		if errors.Is(err, constants.ErrorNotFound) {
+			return Player{}, constants.ErrorPlayerNotFound
+		}
+		return Player{}, err
+	}
+
+	return p, nil
+}
+
+// Authenticate checks the secret against the stored player and returns the
+// player with the plaintext secret filled back in, ready to be handed to the
+// GameManager.
+func (m *PlayerManager) Authenticate(ctx context.Context, id uuid.UUID, secret uuid.UUID) (Player, error) {
+	p, err := m.GetPlayer(ctx, id)
+	if err != nil {
+		return Player{}, err
+	}
+	if !p.CheckSecret(secret) {
+		return Player{}, constants.ErrorPlayerInvalidSecret
+	}
+
+	p.Secret = secret
+	p.SecretSalt = nil
+	p.SecretHash = nil
+
+	return p, nil
+}
+
+func NewPlayerManager(client storage.Client[Player]) *PlayerManager {
+	return &PlayerManager{
+		storageClient: client,
+	}
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rBurgett/scmsh/internal/constants"
+	"github.com/rBurgett/scmsh/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlayerManager(t *testing.T) {
+	ctx := context.Background()
+	m := NewPlayerManager(*storage.NewClient[Player](storage.NewMemDriver(), PlayersNamespace))
+
+	_, err := m.CreatePlayer(ctx, "   ")
+	assert.ErrorIs(t, err, constants.ErrorPlayerInvalidName)
+
+	p, err := m.CreatePlayer(ctx, "Ryan")
+	require.NoError(t, err)
+	assert.NotEqual(t, uuid.Nil, p.Secret)
+
+	stored, err := m.GetPlayer(ctx, p.ID)
+	require.NoError(t, err)
+	assert.Equal(t, uuid.Nil, stored.Secret)
+	assert.True(t, stored.CheckSecret(p.Secret))
+
+	_, err = m.GetPlayer(ctx, uuid.New())
+	assert.ErrorIs(t, err, constants.ErrorPlayerNotFound)
+
+	_, err = m.Authenticate(ctx, p.ID, uuid.New())
+	assert.ErrorIs(t, err, constants.ErrorPlayerInvalidSecret)
+
+	output, err := m.Authenticate(ctx, p.ID, p.Secret)
+	require.NoError(t, err)
+	assert.Equal(t, p, output)
+}
+
+func TestPlayerManager_legacyKey(t *testing.T) {
+	ctx := context.Background()
+	client := storage.NewClient[Player](storage.NewMemDriver(), PlayersNamespace)
+	m := NewPlayerManager(*client)
+
+	p, err := CreatePlayer("Ryan")
+	require.NoError(t, err)
+	require.NoError(t, client.UpsertOne(ctx, legacyKey(p.ID), p))
+
+	stored, err := m.GetPlayer(ctx, p.ID)
+	require.NoError(t, err)
+	assert.Equal(t, p.ID, stored.ID)
+}
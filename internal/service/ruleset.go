@@ -0,0 +1,263 @@
+package service
+
+import (
+	"encoding/json"
+	"maps"
+	"math/rand/v2"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/rBurgett/scmsh/internal/constants"
+)
+
+const (
+	DefaultRulesetName = "default"
+)
+
+// Ruleset holds the outcome of every attacker vs. defender card matchup along
+// with the card inventory and stack shape each player's deck must have. It is
+// stored on the game so that games with different tables can run side by
+// side.
+type Ruleset struct {
+	Name       string
+	Matchups   map[constants.CardType]map[constants.CardType]constants.Outcome
+	CardCounts map[constants.CardType]int
+	StackCount int
+	StackSize  int
+}
+
+func (r *Ruleset) Validate() error {
+	if r.Name == "" {
+		return errors.Wrap(constants.ErrorInvalidRuleset, "missing name")
+	}
+
+	if r.StackCount < 1 || r.StackSize < 1 {
+		return errors.Wrap(constants.ErrorInvalidRuleset, "invalid stack shape")
+	}
+
+	total := 0
+	for card, count := range r.CardCounts {
+		if !slices.Contains(constants.ValidCards, card) {
+			return errors.Wrapf(constants.ErrorInvalidRuleset, "unknown card %d", card)
+		}
+		if count < 0 {
+			return errors.Wrapf(constants.ErrorInvalidRuleset, "invalid count %d for card %d", count, card)
+		}
+		total += count
+	}
+	if r.CardCounts[constants.CardTypeCrown] < 1 {
+		return errors.Wrap(constants.ErrorInvalidRuleset, "no crown")
+	}
+	if total != r.StackCount*r.StackSize {
+		return errors.Wrapf(constants.ErrorInvalidRuleset, "%d cards do not fill %d stacks of %d", total, r.StackCount, r.StackSize)
+	}
+
+	for _, attacker := range constants.ValidCards {
+		for _, defender := range constants.ValidCards {
+			outcome, ok := r.Matchups[attacker][defender]
+			if !ok {
+				return errors.Wrapf(constants.ErrorInvalidRuleset, "missing matchup %d vs %d", attacker, defender)
+			}
+			if outcome < constants.OutcomeAttackerWins || outcome > constants.OutcomeBothDestroyed {
+				return errors.Wrapf(constants.ErrorInvalidRuleset, "invalid outcome %d for %d vs %d", outcome, attacker, defender)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *Ruleset) GetCardCount(cardType constants.CardType) int {
+	return r.CardCounts[cardType]
+}
+
+// GenerateDeck deals the ruleset's card inventory into randomly arranged
+// stacks.
+func (r *Ruleset) GenerateDeck() [][]constants.CardType {
+	var cards []constants.CardType
+	for _, card := range constants.ValidCards {
+		for range r.GetCardCount(card) {
+			cards = append(cards, card)
+		}
+	}
+	rand.Shuffle(len(cards), func(i, j int) {
+		cards[i], cards[j] = cards[j], cards[i]
+	})
+
+	deck := make([][]constants.CardType, r.StackCount)
+	for i := range deck {
+		deck[i] = slices.Clone(cards[i*r.StackSize : (i+1)*r.StackSize])
+	}
+
+	return deck
+}
+
+func (r *Ruleset) Resolve(attacker constants.CardType, defender constants.CardType) (constants.Outcome, error) {
+	outcome, ok := r.Matchups[attacker][defender]
+	if !ok {
+		return 0, constants.ErrorIllegalMove
+	}
+
+	return outcome, nil
+}
+
+func (r *Ruleset) DetermineMoveWinner(m Move) (uuid.UUID, error) {
+	outcome, err := r.Resolve(m.PlayerCardType, m.TargetPlayerCardType)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return m.winnerFor(outcome), nil
+}
+
+func ParseRuleset(data []byte) (Ruleset, error) {
+	var r Ruleset
+	if err := json.Unmarshal(data, &r); err != nil {
+		return Ruleset{}, errors.Wrap(constants.ErrorInvalidRuleset, err.Error())
+	}
+	if err := r.Validate(); err != nil {
+		return Ruleset{}, err
+	}
+
+	return r, nil
+}
+
+// DefaultRuleset returns a copy of the built-in ruleset that callers are free
+// to modify.
+func DefaultRuleset() Ruleset {
+	return copyRuleset(defaultRuleset)
+}
+
+func DetermineMoveWinner(m Move) (uuid.UUID, error) {
+	return defaultRuleset.DetermineMoveWinner(m)
+}
+
+func copyRuleset(r Ruleset) Ruleset {
+	matchups := make(map[constants.CardType]map[constants.CardType]constants.Outcome, len(r.Matchups))
+	for attacker, outcomes := range r.Matchups {
+		matchups[attacker] = maps.Clone(outcomes)
+	}
+	r.Matchups = matchups
+	r.CardCounts = maps.Clone(r.CardCounts)
+
+	return r
+}
+
+var defaultRuleset = Ruleset{
+	Name: DefaultRulesetName,
+	CardCounts: map[constants.CardType]int{
+		constants.CardTypeDagger:     constants.CardCountDagger,
+		constants.CardTypeShortSword: constants.CardCountShortSword,
+		constants.CardTypeMace:       constants.CardCountMace,
+		constants.CardTypeBattleAxe:  constants.CardCountBattleAxe,
+		constants.CardTypeSpear:      constants.CardCountSpear,
+		constants.CardTypeLongSword:  constants.CardCountLongSword,
+		constants.CardTypeArcher:     constants.CardCountArcher,
+		constants.CardTypeShield:     constants.CardCountShield,
+		constants.CardTypeCrown:      constants.CardCountCrown,
+	},
+	StackCount: constants.DeckCount,
+	StackSize:  constants.DeckCardCount,
+	Matchups: map[constants.CardType]map[constants.CardType]constants.Outcome{
+		constants.CardTypeDagger: {
+			constants.CardTypeDagger:     constants.OutcomeBothDestroyed,
+			constants.CardTypeShortSword: constants.OutcomeDefenderWins,
+			constants.CardTypeMace:       constants.OutcomeDefenderWins,
+			constants.CardTypeBattleAxe:  constants.OutcomeDefenderWins,
+			constants.CardTypeSpear:      constants.OutcomeDefenderWins,
+			constants.CardTypeLongSword:  constants.OutcomeDefenderWins,
+			constants.CardTypeArcher:     constants.OutcomeAttackerWins,
+			constants.CardTypeShield:     constants.OutcomeTie,
+			constants.CardTypeCrown:      constants.OutcomeAttackerWins,
+		},
+		constants.CardTypeShortSword: {
+			constants.CardTypeDagger:     constants.OutcomeAttackerWins,
+			constants.CardTypeShortSword: constants.OutcomeBothDestroyed,
+			constants.CardTypeMace:       constants.OutcomeDefenderWins,
+			constants.CardTypeBattleAxe:  constants.OutcomeDefenderWins,
+			constants.CardTypeSpear:      constants.OutcomeDefenderWins,
+			constants.CardTypeLongSword:  constants.OutcomeDefenderWins,
+			constants.CardTypeArcher:     constants.OutcomeAttackerWins,
+			constants.CardTypeShield:     constants.OutcomeTie,
+			constants.CardTypeCrown:      constants.OutcomeAttackerWins,
+		},
+		constants.CardTypeMace: {
+			constants.CardTypeDagger:     constants.OutcomeAttackerWins,
+			constants.CardTypeShortSword: constants.OutcomeAttackerWins,
+			constants.CardTypeMace:       constants.OutcomeBothDestroyed,
+			constants.CardTypeBattleAxe:  constants.OutcomeDefenderWins,
+			constants.CardTypeSpear:      constants.OutcomeDefenderWins,
+			constants.CardTypeLongSword:  constants.OutcomeDefenderWins,
+			constants.CardTypeArcher:     constants.OutcomeAttackerWins,
+			constants.CardTypeShield:     constants.OutcomeTie,
+			constants.CardTypeCrown:      constants.OutcomeAttackerWins,
+		},
+		constants.CardTypeBattleAxe: {
+			constants.CardTypeDagger:     constants.OutcomeAttackerWins,
+			constants.CardTypeShortSword: constants.OutcomeAttackerWins,
+			constants.CardTypeMace:       constants.OutcomeAttackerWins,
+			constants.CardTypeBattleAxe:  constants.OutcomeBothDestroyed,
+			constants.CardTypeSpear:      constants.OutcomeDefenderWins,
+			constants.CardTypeLongSword:  constants.OutcomeDefenderWins,
+			constants.CardTypeArcher:     constants.OutcomeAttackerWins,
+			constants.CardTypeShield:     constants.OutcomeTie,
+			constants.CardTypeCrown:      constants.OutcomeAttackerWins,
+		},
+		constants.CardTypeSpear: {
+			constants.CardTypeDagger:     constants.OutcomeAttackerWins,
+			constants.CardTypeShortSword: constants.OutcomeAttackerWins,
+			constants.CardTypeMace:       constants.OutcomeAttackerWins,
+			constants.CardTypeBattleAxe:  constants.OutcomeAttackerWins,
+			constants.CardTypeSpear:      constants.OutcomeBothDestroyed,
+			constants.CardTypeLongSword:  constants.OutcomeDefenderWins,
+			constants.CardTypeArcher:     constants.OutcomeAttackerWins,
+			constants.CardTypeShield:     constants.OutcomeTie,
+			constants.CardTypeCrown:      constants.OutcomeAttackerWins,
+		},
+		constants.CardTypeLongSword: {
+			constants.CardTypeDagger:     constants.OutcomeAttackerWins,
+			constants.CardTypeShortSword: constants.OutcomeAttackerWins,
+			constants.CardTypeMace:       constants.OutcomeAttackerWins,
+			constants.CardTypeBattleAxe:  constants.OutcomeAttackerWins,
+			constants.CardTypeSpear:      constants.OutcomeAttackerWins,
+			constants.CardTypeLongSword:  constants.OutcomeBothDestroyed,
+			constants.CardTypeArcher:     constants.OutcomeAttackerWins,
+			constants.CardTypeShield:     constants.OutcomeTie,
+			constants.CardTypeCrown:      constants.OutcomeAttackerWins,
+		},
+		constants.CardTypeArcher: {
+			constants.CardTypeDagger:     constants.OutcomeAttackerWins,
+			constants.CardTypeShortSword: constants.OutcomeAttackerWins,
+			constants.CardTypeMace:       constants.OutcomeAttackerWins,
+			constants.CardTypeBattleAxe:  constants.OutcomeAttackerWins,
+			constants.CardTypeSpear:      constants.OutcomeAttackerWins,
+			constants.CardTypeLongSword:  constants.OutcomeAttackerWins,
+			constants.CardTypeArcher:     constants.OutcomeAttackerWins,
+			constants.CardTypeShield:     constants.OutcomeAttackerWins,
+			constants.CardTypeCrown:      constants.OutcomeAttackerWins,
+		},
+		constants.CardTypeShield: {
+			constants.CardTypeDagger:     constants.OutcomeDefenderWins,
+			constants.CardTypeShortSword: constants.OutcomeDefenderWins,
+			constants.CardTypeMace:       constants.OutcomeDefenderWins,
+			constants.CardTypeBattleAxe:  constants.OutcomeDefenderWins,
+			constants.CardTypeSpear:      constants.OutcomeDefenderWins,
+			constants.CardTypeLongSword:  constants.OutcomeDefenderWins,
+			constants.CardTypeArcher:     constants.OutcomeDefenderWins,
+			constants.CardTypeShield:     constants.OutcomeDefenderWins,
+			constants.CardTypeCrown:      constants.OutcomeDefenderWins,
+		},
+		constants.CardTypeCrown: {
+			constants.CardTypeDagger:     constants.OutcomeDefenderWins,
+			constants.CardTypeShortSword: constants.OutcomeDefenderWins,
+			constants.CardTypeMace:       constants.OutcomeDefenderWins,
+			constants.CardTypeBattleAxe:  constants.OutcomeDefenderWins,
+			constants.CardTypeSpear:      constants.OutcomeDefenderWins,
+			constants.CardTypeLongSword:  constants.OutcomeDefenderWins,
+			constants.CardTypeArcher:     constants.OutcomeAttackerWins,
+			constants.CardTypeShield:     constants.OutcomeTie,
+			constants.CardTypeCrown:      constants.OutcomeAttackerWins,
+		},
+	},
+}
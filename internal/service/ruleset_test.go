@@ -0,0 +1,274 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rBurgett/scmsh/internal/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSmallRuleset returns a quick variant with a single card of each type
+// dealt into three stacks of three.
+func newSmallRuleset() Ruleset {
+	r := DefaultRuleset()
+	r.Name = "small"
+	r.StackCount = 3
+	r.StackSize = 3
+	for _, card := range constants.ValidCards {
+		r.CardCounts[card] = 1
+	}
+
+	return r
+}
+
+func TestRuleset_Validate(t *testing.T) {
+	missing := DefaultRuleset()
+	delete(missing.Matchups[constants.CardTypeCrown], constants.CardTypeShield)
+
+	invalidOutcome := DefaultRuleset()
+	invalidOutcome.Matchups[constants.CardTypeDagger][constants.CardTypeDagger] = 0
+
+	unnamed := DefaultRuleset()
+	unnamed.Name = ""
+
+	twoCrowns := DefaultRuleset()
+	twoCrowns.CardCounts[constants.CardTypeCrown] = 2
+	twoCrowns.CardCounts[constants.CardTypeDagger] = 3
+
+	overfilled := DefaultRuleset()
+	overfilled.CardCounts[constants.CardTypeCrown] = 2
+
+	unknownCard := DefaultRuleset()
+	unknownCard.CardCounts[constants.CardType(42)] = 0
+
+	noStacks := DefaultRuleset()
+	noStacks.StackCount = 0
+
+	noCrown := DefaultRuleset()
+	noCrown.CardCounts[constants.CardTypeCrown] = 0
+	noCrown.CardCounts[constants.CardTypeDagger]++
+
+	tests := []struct {
+		name          string
+		ruleset       Ruleset
+		expectedError error
+	}{
+		{
+			name:    "default ruleset",
+			ruleset: DefaultRuleset(),
+		},
+		{
+			name:    "small ruleset",
+			ruleset: newSmallRuleset(),
+		},
+		{
+			name:    "two crowns",
+			ruleset: twoCrowns,
+		},
+		{
+			name:          "invalid - cards do not fill stacks",
+			ruleset:       overfilled,
+			expectedError: constants.ErrorInvalidRuleset,
+		},
+		{
+			name:          "invalid - unknown card",
+			ruleset:       unknownCard,
+			expectedError: constants.ErrorInvalidRuleset,
+		},
+		{
+			name:          "invalid - no crown",
+			ruleset:       noCrown,
+			expectedError: constants.ErrorInvalidRuleset,
+		},
+		{
+			name:          "invalid - no stacks",
+			ruleset:       noStacks,
+			expectedError: constants.ErrorInvalidRuleset,
+		},
+		{
+			name:          "invalid - missing matchup",
+			ruleset:       missing,
+			expectedError: constants.ErrorInvalidRuleset,
+		},
+		{
+			name:          "invalid - bad outcome",
+			ruleset:       invalidOutcome,
+			expectedError: constants.ErrorInvalidRuleset,
+		},
+		{
+			name:          "invalid - missing name",
+			ruleset:       unnamed,
+			expectedError: constants.ErrorInvalidRuleset,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.ruleset.Validate()
+			if tt.expectedError != nil {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestRuleset_GenerateDeck(t *testing.T) {
+	for _, r := range []Ruleset{DefaultRuleset(), newSmallRuleset()} {
+		t.Run(r.Name, func(t *testing.T) {
+			p := Player{Deck: r.GenerateDeck()}
+			assert.NoError(t, p.ValidateDeck(&r))
+		})
+	}
+}
+
+func TestRuleset_Resolve(t *testing.T) {
+	r := DefaultRuleset()
+
+	tests := []struct {
+		name          string
+		attacker      constants.CardType
+		defender      constants.CardType
+		expected      constants.Outcome
+		expectedError error
+	}{
+		{
+			name:     "attacker wins",
+			attacker: constants.CardTypeSpear,
+			defender: constants.CardTypeDagger,
+			expected: constants.OutcomeAttackerWins,
+		},
+		{
+			name:     "defender wins",
+			attacker: constants.CardTypeShield,
+			defender: constants.CardTypeDagger,
+			expected: constants.OutcomeDefenderWins,
+		},
+		{
+			name:     "shield ties",
+			attacker: constants.CardTypeLongSword,
+			defender: constants.CardTypeShield,
+			expected: constants.OutcomeTie,
+		},
+		{
+			name:     "equal cards destroy each other",
+			attacker: constants.CardTypeMace,
+			defender: constants.CardTypeMace,
+			expected: constants.OutcomeBothDestroyed,
+		},
+		{
+			name:          "invalid - unknown card",
+			attacker:      constants.CardType(42),
+			defender:      constants.CardTypeMace,
+			expectedError: constants.ErrorIllegalMove,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := r.Resolve(tt.attacker, tt.defender)
+			if tt.expectedError != nil {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, output)
+		})
+	}
+}
+
+func TestParseRuleset(t *testing.T) {
+	custom := DefaultRuleset()
+	custom.Name = "archers"
+	custom.Matchups[constants.CardTypeArcher][constants.CardTypeShield] = constants.OutcomeTie
+	valid, err := json.Marshal(custom)
+	require.NoError(t, err)
+
+	incomplete := DefaultRuleset()
+	delete(incomplete.Matchups, constants.CardTypeArcher)
+	invalid, err := json.Marshal(incomplete)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		data          []byte
+		expected      Ruleset
+		expectedError error
+	}{
+		{
+			name:     "valid ruleset",
+			data:     valid,
+			expected: custom,
+		},
+		{
+			name:          "invalid - incomplete",
+			data:          invalid,
+			expectedError: constants.ErrorInvalidRuleset,
+		},
+		{
+			name:          "invalid - bad json",
+			data:          []byte(`{"Name":`),
+			expectedError: constants.ErrorInvalidRuleset,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := ParseRuleset(tt.data)
+			if tt.expectedError != nil {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, output)
+		})
+	}
+}
+
+func TestGame_GetRuleset(t *testing.T) {
+	owner := newTestPlayer(t, "Ryan")
+	joiner := newTestPlayer(t, "Isaac")
+
+	g, err := CreateGame(owner)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultRulesetName, g.GetRuleset().Name)
+
+	custom := DefaultRuleset()
+	custom.Name = "archers"
+	custom.Matchups[constants.CardTypeArcher][constants.CardTypeShield] = constants.OutcomeTie
+
+	err = g.SetRuleset(joiner.ID, custom)
+	assert.ErrorIs(t, err, constants.ErrorPlayerNotOwner)
+
+	require.NoError(t, g.SubmitDeck(owner.ID, newTestDeck()))
+	require.NoError(t, g.SetReady(owner.ID))
+
+	require.NoError(t, g.SetRuleset(owner.ID, custom))
+	assert.Equal(t, "archers", g.GetRuleset().Name)
+
+	p, err := g.GetPlayer(owner.ID)
+	require.NoError(t, err)
+	assert.Nil(t, p.Deck)
+	assert.Equal(t, constants.PlayerStatusAccepted, p.Status)
+
+	m := Move{
+		Player:               owner.ID,
+		PlayerCardType:       constants.CardTypeArcher,
+		TargetPlayer:         joiner.ID,
+		TargetPlayerCardType: constants.CardTypeShield,
+	}
+	winner, err := g.GetRuleset().DetermineMoveWinner(m)
+	require.NoError(t, err)
+	assert.Equal(t, uuid.Nil, winner)
+
+	winner, err = DetermineMoveWinner(m)
+	require.NoError(t, err)
+	assert.Equal(t, owner.ID, winner)
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"github.com/google/uuid"
+	"github.com/rBurgett/scmsh/internal/constants"
+)
+
+func (g *Game) ActivePlayers() []uuid.UUID {
+	var res []uuid.UUID
+	for _, p := range g.Players {
+		if p.IsActive() {
+			res = append(res, p.ID)
+		}
+	}
+
+	return res
+}
+
+func (g *Game) startTurns() error {
+	active := g.ActivePlayers()
+	if len(active) < 2 {
+		return constants.ErrorNotEnoughPlayers
+	}
+
+	g.CurrentPlayer = active[0]
+	g.Turn = 1
+
+	return nil
+}
+
+func (g *Game) advanceTurn() {
+	g.Turn++
+	g.CurrentPlayer = g.nextPlayer()
+}
+
+// nextPlayer returns the first active player after the current one in seating
+// order, wrapping around. The current player is returned if nobody else is
+// left, and uuid.Nil if nobody is left at all.
+func (g *Game) nextPlayer() uuid.UUID {
+	current := -1
+	for i, p := range g.Players {
+		if p.ID == g.CurrentPlayer {
+			current = i
+			break
+		}
+	}
+
+	for i := 1; i <= len(g.Players); i++ {
+		p := g.Players[(current+i+len(g.Players))%len(g.Players)]
+		if p.IsActive() {
+			return p.ID
+		}
+	}
+
+	return uuid.Nil
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rBurgett/scmsh/internal/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGame_Start(t *testing.T) {
+	playerID1 := uuid.New()
+	playerID2 := uuid.New()
+	playerID3 := uuid.New()
+
+	tests := []struct {
+		name          string
+		game          Game
+		expected      uuid.UUID
+		expectedError error
+	}{
+		{
+			name: "first active player goes first",
+			game: Game{
+				Players: []Player{
+					{ID: playerID1},
+					{ID: playerID2, Deck: [][]constants.CardType{{constants.CardTypeDagger}}},
+					{ID: playerID3, Deck: [][]constants.CardType{{constants.CardTypeMace}}},
+				},
+				Status: constants.GameStatusOpen,
+			},
+			expected: playerID2,
+		},
+		{
+			name: "invalid - not enough players",
+			game: Game{
+				Players: []Player{
+					{ID: playerID1, Deck: [][]constants.CardType{{constants.CardTypeDagger}}},
+					{ID: playerID2, Deck: [][]constants.CardType{{}}},
+				},
+				Status: constants.GameStatusOpen,
+			},
+			expectedError: constants.ErrorNotEnoughPlayers,
+		},
+		{
+			name: "invalid - already started",
+			game: Game{
+				Status: constants.GameStatusStarted,
+			},
+			expectedError: constants.ErrorGameAlreadyStarted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.game.Start()
+			if tt.expectedError != nil {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, constants.GameStatus(constants.GameStatusStarted), tt.game.Status)
+			assert.Equal(t, tt.expected, tt.game.CurrentPlayer)
+			assert.Equal(t, 1, tt.game.Turn)
+		})
+	}
+}
+
+func TestGame_advanceTurn(t *testing.T) {
+	playerID1 := uuid.New()
+	playerID2 := uuid.New()
+	playerID3 := uuid.New()
+	deck := [][]constants.CardType{{constants.CardTypeDagger}}
+
+	tests := []struct {
+		name     string
+		game     Game
+		expected uuid.UUID
+	}{
+		{
+			name: "next player",
+			game: Game{
+				CurrentPlayer: playerID1,
+				Players: []Player{
+					{ID: playerID1, Deck: deck},
+					{ID: playerID2, Deck: deck},
+					{ID: playerID3, Deck: deck},
+				},
+			},
+			expected: playerID2,
+		},
+		{
+			name: "wraps around",
+			game: Game{
+				CurrentPlayer: playerID3,
+				Players: []Player{
+					{ID: playerID1, Deck: deck},
+					{ID: playerID2, Deck: deck},
+					{ID: playerID3, Deck: deck},
+				},
+			},
+			expected: playerID1,
+		},
+		{
+			name: "skips lost players and empty decks",
+			game: Game{
+				CurrentPlayer: playerID1,
+				Players: []Player{
+					{ID: playerID1, Deck: deck},
+					{ID: playerID2, Deck: deck, Status: constants.PlayerStatusLost},
+					{ID: playerID3, Deck: [][]constants.CardType{{}, {}}},
+				},
+			},
+			expected: playerID1,
+		},
+		{
+			name: "nobody left",
+			game: Game{
+				CurrentPlayer: playerID1,
+				Players: []Player{
+					{ID: playerID1},
+					{ID: playerID2},
+				},
+			},
+			expected: uuid.Nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.game.Turn = 3
+			tt.game.advanceTurn()
+			assert.Equal(t, tt.expected, tt.game.CurrentPlayer)
+			assert.Equal(t, 4, tt.game.Turn)
+		})
+	}
+}
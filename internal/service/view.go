@@ -0,0 +1,127 @@
+package service
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rBurgett/scmsh/internal/constants"
+)
+
+// GameView is what a single viewer is allowed to see of a game. Secrets are
+// never included and opponents' stacks are reduced to their heights plus any
+// top card that was revealed in battle and is still there. Spectators see no
+// cards at all, not even in the moves, until the game is done.
+type GameView struct {
+	ID            uuid.UUID
+	Viewer        uuid.UUID
+	CurrentPlayer uuid.UUID
+	Moves         []Move
+	Owner         uuid.UUID
+	Players       []PlayerView
+	Status        constants.GameStatus
+	Turn          int
+	Ruleset       *Ruleset
+	Standings     []uuid.UUID
+	CreatedAt     time.Time
+	FinishedAt    time.Time
+}
+
+type PlayerView struct {
+	ID     uuid.UUID
+	Name   string
+	Status constants.PlayerStatus
+	Stacks []StackView
+}
+
+// StackView holds the height of a stack and the cards the viewer can see,
+// starting from the top.
+type StackView struct {
+	Height int
+	Cards  []constants.CardType
+}
+
+// ViewFor projects the game for the given player. Anybody who is not in the
+// game gets the spectator view. Once the game is done every card is shown.
+func (g *Game) ViewFor(viewerID uuid.UUID) GameView {
+	if _, err := g.GetPlayer(viewerID); err != nil {
+		viewerID = uuid.Nil
+	}
+	spectator := viewerID == uuid.Nil && g.Status != constants.GameStatusDone
+
+	revealed := g.revealedStacks()
+
+	players := make([]PlayerView, len(g.Players))
+	for i, p := range g.Players {
+		stacks := make([]StackView, len(p.Deck))
+		for pos, stack := range p.Deck {
+			s := StackView{
+				Height: len(stack),
+			}
+			switch {
+			case p.ID == viewerID || g.Status == constants.GameStatusDone:
+				s.Cards = slices.Clone(stack)
+			case !spectator && revealed[stackPosition{p.ID, pos}] && len(stack) > 0:
+				s.Cards = []constants.CardType{stack[0]}
+			}
+			stacks[pos] = s
+		}
+
+		players[i] = PlayerView{
+			ID:     p.ID,
+			Name:   p.Name,
+			Status: p.Status,
+			Stacks: stacks,
+		}
+	}
+
+	moves := slices.Clone(g.Moves)
+	if spectator {
+		for i := range moves {
+			moves[i].PlayerCardType = 0
+			moves[i].TargetPlayerCardType = 0
+		}
+	}
+
+	var ruleset *Ruleset
+	if g.Ruleset != nil {
+		r := copyRuleset(*g.Ruleset)
+		ruleset = &r
+	}
+
+	return GameView{
+		ID:            g.ID,
+		Viewer:        viewerID,
+		CurrentPlayer: g.CurrentPlayer,
+		Moves:         moves,
+		Owner:         g.Owner,
+		Players:       players,
+		Status:        g.Status,
+		Turn:          g.Turn,
+		Ruleset:       ruleset,
+		Standings:     slices.Clone(g.Standings),
+		CreatedAt:     g.CreatedAt,
+		FinishedAt:    g.FinishedAt,
+	}
+}
+
+func (g *Game) SpectatorView() GameView {
+	return g.ViewFor(uuid.Nil)
+}
+
+type stackPosition struct {
+	player   uuid.UUID
+	position int
+}
+
+// revealedStacks works out which stacks currently have a face-up top card. A
+// card is revealed when it fights, and stays revealed until it is destroyed.
+func (g *Game) revealedStacks() map[stackPosition]bool {
+	res := map[stackPosition]bool{}
+	for _, m := range g.Moves {
+		res[stackPosition{m.Player, m.PlayerCardPosition}] = m.Outcome == constants.OutcomeAttackerWins || m.Outcome == constants.OutcomeTie
+		res[stackPosition{m.TargetPlayer, m.TargetPlayerCardPosition}] = m.Outcome == constants.OutcomeDefenderWins || m.Outcome == constants.OutcomeTie
+	}
+
+	return res
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rBurgett/scmsh/internal/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGame_ViewFor(t *testing.T) {
+	playerID1 := uuid.New()
+	playerID2 := uuid.New()
+
+	newGame := func() Game {
+		return Game{
+			CurrentPlayer: playerID1,
+			Players: []Player{
+				{
+					ID:     playerID1,
+					Name:   "Ryan",
+					Secret: uuid.New(),
+					Deck: [][]constants.CardType{
+						{constants.CardTypeLongSword, constants.CardTypeDagger},
+						{constants.CardTypeMace},
+					},
+				},
+				{
+					ID:     playerID2,
+					Name:   "Isaac",
+					Secret: uuid.New(),
+					Deck: [][]constants.CardType{
+						{constants.CardTypeShield, constants.CardTypeCrown},
+						{constants.CardTypeSpear, constants.CardTypeArcher},
+					},
+				},
+			},
+			Status: constants.GameStatusStarted,
+			Turn:   1,
+		}
+	}
+
+	g := newGame()
+	_, err := g.ExecuteMove(playerID1, 0, playerID2, 0)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		viewerID uuid.UUID
+		status   constants.GameStatus
+		expected []PlayerView
+	}{
+		{
+			name:     "player sees own stacks and revealed opponent cards",
+			viewerID: playerID1,
+			status:   constants.GameStatusStarted,
+			expected: []PlayerView{
+				{ID: playerID1, Name: "Ryan", Stacks: []StackView{
+					{Height: 2, Cards: []constants.CardType{constants.CardTypeLongSword, constants.CardTypeDagger}},
+					{Height: 1, Cards: []constants.CardType{constants.CardTypeMace}},
+				}},
+				{ID: playerID2, Name: "Isaac", Stacks: []StackView{
+					{Height: 2, Cards: []constants.CardType{constants.CardTypeShield}},
+					{Height: 2},
+				}},
+			},
+		},
+		{
+			name:     "spectator sees no cards",
+			viewerID: uuid.New(),
+			status:   constants.GameStatusStarted,
+			expected: []PlayerView{
+				{ID: playerID1, Name: "Ryan", Stacks: []StackView{
+					{Height: 2},
+					{Height: 1},
+				}},
+				{ID: playerID2, Name: "Isaac", Stacks: []StackView{
+					{Height: 2},
+					{Height: 2},
+				}},
+			},
+		},
+		{
+			name:     "everything is shown after the game",
+			viewerID: uuid.Nil,
+			status:   constants.GameStatusDone,
+			expected: []PlayerView{
+				{ID: playerID1, Name: "Ryan", Stacks: []StackView{
+					{Height: 2, Cards: []constants.CardType{constants.CardTypeLongSword, constants.CardTypeDagger}},
+					{Height: 1, Cards: []constants.CardType{constants.CardTypeMace}},
+				}},
+				{ID: playerID2, Name: "Isaac", Stacks: []StackView{
+					{Height: 2, Cards: []constants.CardType{constants.CardTypeShield, constants.CardTypeCrown}},
+					{Height: 2, Cards: []constants.CardType{constants.CardTypeSpear, constants.CardTypeArcher}},
+				}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := g
+			g.Status = tt.status
+			output := g.ViewFor(tt.viewerID)
+			assert.Equal(t, tt.expected, output.Players)
+			if tt.viewerID == playerID1 {
+				assert.Equal(t, playerID1, output.Viewer)
+			} else {
+				assert.Equal(t, uuid.Nil, output.Viewer)
+			}
+
+			if tt.viewerID != playerID1 && tt.status != constants.GameStatusDone {
+				require.Len(t, output.Moves, 1)
+				assert.Zero(t, output.Moves[0].PlayerCardType)
+				assert.Zero(t, output.Moves[0].TargetPlayerCardType)
+				assert.Equal(t, g.Moves[0].Outcome, output.Moves[0].Outcome)
+			} else {
+				assert.Equal(t, g.Moves, output.Moves)
+			}
+		})
+	}
+}
+
+func TestGame_ViewFor_ruleset(t *testing.T) {
+	r := DefaultRuleset()
+	g := Game{Ruleset: &r, Status: constants.GameStatusOpen}
+
+	view := g.ViewFor(uuid.Nil)
+	require.NotNil(t, view.Ruleset)
+	assert.Equal(t, r, *view.Ruleset)
+
+	view.Ruleset.CardCounts[constants.CardTypeCrown] = 5
+	view.Ruleset.Matchups[constants.CardTypeCrown][constants.CardTypeCrown] = constants.OutcomeTie
+	assert.Equal(t, DefaultRuleset(), *g.Ruleset)
+}
+
+func TestGame_revealedStacks(t *testing.T) {
+	playerID1 := uuid.New()
+	playerID2 := uuid.New()
+
+	g := Game{
+		Moves: []Move{
+			{Player: playerID1, PlayerCardPosition: 0, TargetPlayer: playerID2, TargetPlayerCardPosition: 1, Outcome: constants.OutcomeAttackerWins},
+			{Player: playerID2, PlayerCardPosition: 0, TargetPlayer: playerID1, TargetPlayerCardPosition: 0, Outcome: constants.OutcomeAttackerWins},
+		},
+	}
+
+	output := g.revealedStacks()
+	assert.Equal(t, map[stackPosition]bool{
+		{playerID1, 0}: false,
+		{playerID2, 1}: false,
+		{playerID2, 0}: true,
+	}, output)
+}
@@ -0,0 +1,295 @@
+package storage
+
+import (
+	"container/list"
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CacheDriver keeps the most recently read records of another driver in
+// memory. Reads of single records are served from the cache while it holds
+// them, everything else goes straight through.
+//
+// Records are dropped from the cache when they are written through the
+// CacheDriver, when the wrapped driver's change feed reports a write from
+// elsewhere and when they have been cached for longer than the TTL. Until the
+// change feed of a namespace is up, records of that namespace are not cached.
+// Records that expire in the wrapped driver are not reported on the feed, so
+// the cache can serve them for up to the TTL after they are gone.
+type CacheDriver struct {
+	driver Driver
+	size   int
+	ttl    time.Duration
+	now    func() time.Time
+
+	m     sync.Mutex
+	items map[string]*list.Element
+	lru   *list.List
+	// generation is bumped on every invalidation, so a read that raced with
+	// one does not put what it read into the cache.
+	generation uint64
+
+	watchMu  sync.Mutex
+	watching map[string]bool
+	ctx      context.Context
+	cancel   context.CancelFunc
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+type cacheItem struct {
+	key       string
+	namespace string
+	value     string
+	version   int64
+	at        time.Time
+}
+
+// CacheStats counts the reads served by the cache and those that had to go to
+// the wrapped driver.
+type CacheStats struct {
+	Hits   int64
+	Misses int64
+	Size   int
+}
+
+func (d *CacheDriver) generateKey(namespace string, id ID) string {
+	return namespace + ":" + id.String()
+}
+
+func (d *CacheDriver) FindAll(ctx context.Context, namespace string) (res []string, err error) {
+	return d.driver.FindAll(ctx, namespace)
+}
+
+func (d *CacheDriver) FindPage(ctx context.Context, namespace string, cursor string, limit int) (res []string, next string, err error) {
+	return d.driver.FindPage(ctx, namespace, cursor, limit)
+}
+
+func (d *CacheDriver) FindOne(ctx context.Context, namespace string, id ID) (res string, err error) {
+	res, _, err = d.FindOneVersioned(ctx, namespace, id)
+	return res, err
+}
+
+func (d *CacheDriver) FindOneVersioned(ctx context.Context, namespace string, id ID) (res string, version int64, err error) {
+	key := d.generateKey(namespace, id)
+	if item, ok := d.get(key); ok {
+		d.hits.Add(1)
+		return item.value, item.version, nil
+	}
+	d.misses.Add(1)
+
+	watched := d.watch(namespace)
+	d.m.Lock()
+	generation := d.generation
+	d.m.Unlock()
+
+	res, version, err = d.driver.FindOneVersioned(ctx, namespace, id)
+	if err != nil {
+		return "", 0, err
+	}
+	if watched {
+		d.put(generation, &cacheItem{key: key, namespace: namespace, value: res, version: version})
+	}
+
+	return res, version, nil
+}
+
+func (d *CacheDriver) FindIndex(ctx context.Context, namespace string, index string, value string, min float64, max float64) (res []string, err error) {
+	return d.driver.FindIndex(ctx, namespace, index, value, min, max)
+}
+
+func (d *CacheDriver) UpsertOne(ctx context.Context, namespace string, id ID, value string, entries ...IndexEntry) error {
+	defer d.invalidate(d.generateKey(namespace, id))
+	return d.driver.UpsertOne(ctx, namespace, id, value, entries...)
+}
+
+func (d *CacheDriver) UpsertOneTTL(ctx context.Context, namespace string, id ID, value string, ttl time.Duration, entries ...IndexEntry) error {
+	defer d.invalidate(d.generateKey(namespace, id))
+	return d.driver.UpsertOneTTL(ctx, namespace, id, value, ttl, entries...)
+}
+
+func (d *CacheDriver) CompareAndSwap(ctx context.Context, namespace string, id ID, version int64, value string, ttl time.Duration, entries ...IndexEntry) error {
+	defer d.invalidate(d.generateKey(namespace, id))
+	return d.driver.CompareAndSwap(ctx, namespace, id, version, value, ttl, entries...)
+}
+
+func (d *CacheDriver) Expire(ctx context.Context, namespace string, id ID, ttl time.Duration) error {
+	defer d.invalidate(d.generateKey(namespace, id))
+	return d.driver.Expire(ctx, namespace, id, ttl)
+}
+
+func (d *CacheDriver) DeleteOne(ctx context.Context, namespace string, id ID) error {
+	defer d.invalidate(d.generateKey(namespace, id))
+	return d.driver.DeleteOne(ctx, namespace, id)
+}
+
+func (d *CacheDriver) Commit(ctx context.Context, ops []Op) error {
+	keys := make([]string, len(ops))
+	for i, op := range ops {
+		keys[i] = op.key()
+	}
+	defer d.invalidate(keys...)
+
+	return d.driver.Commit(ctx, ops)
+}
+
+func (d *CacheDriver) Watch(ctx context.Context, namespace string) (<-chan Change, error) {
+	return d.driver.Watch(ctx, namespace)
+}
+
+// Stats returns the hit and miss counts since the driver was created, along
+// with the number of records cached right now.
+func (d *CacheDriver) Stats() CacheStats {
+	d.m.Lock()
+	size := d.lru.Len()
+	d.m.Unlock()
+
+	return CacheStats{
+		Hits:   d.hits.Load(),
+		Misses: d.misses.Load(),
+		Size:   size,
+	}
+}
+
+// get returns the cached record, unless it is missing or has outlived the
+// TTL, and marks it as recently used.
+func (d *CacheDriver) get(key string) (*cacheItem, bool) {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	el, ok := d.items[key]
+	if !ok {
+		return nil, false
+	}
+	item := el.Value.(*cacheItem)
+	if d.ttl > 0 && d.clock().Sub(item.at) >= d.ttl {
+		d.remove(el)
+		return nil, false
+	}
+	d.lru.MoveToFront(el)
+
+	return item, true
+}
+
+// put caches the record, evicting the least recently used ones to make room,
+// unless something was invalidated since generation.
+func (d *CacheDriver) put(generation uint64, item *cacheItem) {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	if d.generation != generation {
+		return
+	}
+	if el, ok := d.items[item.key]; ok {
+		d.remove(el)
+	}
+
+	item.at = d.clock()
+	d.items[item.key] = d.lru.PushFront(item)
+	for d.lru.Len() > d.size {
+		d.remove(d.lru.Back())
+	}
+}
+
+// remove drops a cached record. The lock must be held.
+func (d *CacheDriver) remove(el *list.Element) {
+	d.lru.Remove(el)
+	delete(d.items, el.Value.(*cacheItem).key)
+}
+
+func (d *CacheDriver) invalidate(keys ...string) {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	d.generation++
+	for _, key := range keys {
+		if el, ok := d.items[key]; ok {
+			d.remove(el)
+		}
+	}
+}
+
+// invalidateNamespace drops every cached record of the namespace.
+func (d *CacheDriver) invalidateNamespace(namespace string) {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	d.generation++
+	for _, el := range d.items {
+		if el.Value.(*cacheItem).namespace == namespace {
+			d.remove(el)
+		}
+	}
+}
+
+// watch makes sure the change feed of the namespace is being followed, and
+// reports whether it is.
+func (d *CacheDriver) watch(namespace string) bool {
+	d.watchMu.Lock()
+	defer d.watchMu.Unlock()
+
+	if d.watching[namespace] {
+		return true
+	}
+
+	changes, err := d.driver.Watch(d.ctx, namespace)
+	if err != nil {
+		return false
+	}
+	d.watching[namespace] = true
+
+	go func() {
+		for change := range changes {
+			d.invalidate(d.generateKey(namespace, StringID(change.ID)))
+		}
+
+		// The feed was closed, either because the driver is closing or
+		// because we fell behind. Either way, whatever was missed could
+		// have changed anything.
+		d.watchMu.Lock()
+		delete(d.watching, namespace)
+		d.watchMu.Unlock()
+		d.invalidateNamespace(namespace)
+	}()
+
+	return true
+}
+
+func (d *CacheDriver) clock() time.Time {
+	if d.now == nil {
+		return time.Now()
+	}
+	return d.now()
+}
+
+// Close stops following the change feeds and closes the wrapped driver.
+func (d *CacheDriver) Close() error {
+	d.cancel()
+
+	if c, ok := d.driver.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+// NewCacheDriver caches up to size records read from driver, for up to ttl
+// each. A ttl of 0 keeps them until they are written or evicted.
+func NewCacheDriver(driver Driver, size int, ttl time.Duration) *CacheDriver {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &CacheDriver{
+		driver:   driver,
+		size:     size,
+		ttl:      ttl,
+		items:    map[string]*list.Element{},
+		lru:      list.New(),
+		watching: map[string]bool{},
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/oklog/ulid/v2"
+	"github.com/rBurgett/scmsh/internal/config"
+	"github.com/rBurgett/scmsh/internal/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheDriver(t *testing.T) {
+	ctx := context.Background()
+	md := NewMemDriver()
+	d := NewCacheDriver(md, 2, 0)
+	defer d.Close()
+	id := ulid.Make()
+
+	require.NoError(t, d.UpsertOne(ctx, "test", id, `{"some":"thing1"}`))
+	for range 2 {
+		data, version, err := d.FindOneVersioned(ctx, "test", id)
+		require.NoError(t, err)
+		assert.Equal(t, `{"some":"thing1"}`, data)
+		assert.Equal(t, int64(1), version)
+	}
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 1, Size: 1}, d.Stats())
+
+	require.NoError(t, d.CompareAndSwap(ctx, "test", id, 1, `{"some":"thing2"}`, 0))
+	data, err := d.FindOne(ctx, "test", id)
+	require.NoError(t, err)
+	assert.Equal(t, `{"some":"thing2"}`, data)
+
+	require.NoError(t, d.Commit(ctx, []Op{{Type: ChangeUpsert, Namespace: "test", ID: id, Value: `{"some":"thing3"}`}}))
+	data, err = d.FindOne(ctx, "test", id)
+	require.NoError(t, err)
+	assert.Equal(t, `{"some":"thing3"}`, data)
+
+	require.NoError(t, d.DeleteOne(ctx, "test", id))
+	_, err = d.FindOne(ctx, "test", id)
+	assert.ErrorIs(t, err, constants.ErrorNotFound)
+	assert.Equal(t, 0, d.Stats().Size)
+}
+
+func TestCacheDriver_lru(t *testing.T) {
+	ctx := context.Background()
+	d := NewCacheDriver(NewMemDriver(), 2, 0)
+	defer d.Close()
+	ids := []ulid.ULID{ulid.Make(), ulid.Make(), ulid.Make()}
+
+	for _, id := range ids {
+		require.NoError(t, d.UpsertOne(ctx, "test", id, id.String()))
+	}
+	for _, id := range ids[:2] {
+		_, err := d.FindOne(ctx, "test", id)
+		require.NoError(t, err)
+	}
+	// Touch the first so the second is the least recently used.
+	_, err := d.FindOne(ctx, "test", ids[0])
+	require.NoError(t, err)
+	_, err = d.FindOne(ctx, "test", ids[2])
+	require.NoError(t, err)
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 3, Size: 2}, d.Stats())
+
+	_, err = d.FindOne(ctx, "test", ids[0])
+	require.NoError(t, err)
+	_, err = d.FindOne(ctx, "test", ids[1])
+	require.NoError(t, err)
+	assert.Equal(t, CacheStats{Hits: 2, Misses: 4, Size: 2}, d.Stats())
+}
+
+func TestCacheDriver_ttl(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	d := NewCacheDriver(NewMemDriver(), 10, time.Minute)
+	d.now = func() time.Time { return now }
+	defer d.Close()
+	id := ulid.Make()
+
+	require.NoError(t, d.UpsertOne(ctx, "test", id, `{"some":"thing1"}`))
+	_, err := d.FindOne(ctx, "test", id)
+	require.NoError(t, err)
+
+	now = now.Add(59 * time.Second)
+	_, err = d.FindOne(ctx, "test", id)
+	require.NoError(t, err)
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 1, Size: 1}, d.Stats())
+
+	now = now.Add(time.Second)
+	_, err = d.FindOne(ctx, "test", id)
+	require.NoError(t, err)
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 2, Size: 1}, d.Stats())
+}
+
+func TestCacheDriver_changeFeed(t *testing.T) {
+	ctx := context.Background()
+	s := miniredis.RunT(t)
+	cfg := config.Config{
+		RedisAddress: s.Addr(),
+	}
+	d := NewCacheDriver(NewRedisDriver(cfg), 10, 0)
+	defer d.Close()
+	// Another instance writing to the same Redis.
+	other := NewRedisDriver(cfg)
+	defer other.Close()
+	id := ulid.Make()
+
+	require.NoError(t, other.UpsertOne(ctx, "test", id, `{"some":"thing1"}`))
+	data, err := d.FindOne(ctx, "test", id)
+	require.NoError(t, err)
+	assert.Equal(t, `{"some":"thing1"}`, data)
+
+	require.NoError(t, other.UpsertOne(ctx, "test", id, `{"some":"thing2"}`))
+	assert.Eventually(t, func() bool {
+		data, err := d.FindOne(ctx, "test", id)
+		return err == nil && data == `{"some":"thing2"}`
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, other.DeleteOne(ctx, "test", id))
+	assert.Eventually(t, func() bool {
+		_, err := d.FindOne(ctx, "test", id)
+		return err != nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestNewCacheDriver(t *testing.T) {
+	md := NewMemDriver()
+	d := NewCacheDriver(md, 10, time.Minute)
+	assert.Equal(t, md, d.driver)
+	assert.Equal(t, 10, d.size)
+	assert.Equal(t, time.Minute, d.ttl)
+	require.NoError(t, d.Close())
+
+	driver, err := NewDriver(config.Config{CacheSize: 10})
+	require.NoError(t, err)
+	assert.IsType(t, &CacheDriver{}, driver)
+}
@@ -2,62 +2,216 @@ package storage
 
 import (
 	"context"
-	"encoding/json"
+	"slices"
+	"time"
 
-	"github.com/oklog/ulid/v2"
+	"github.com/pkg/errors"
+	"github.com/rBurgett/scmsh/internal/config"
 )
 
+// ID is anything a record can be stored under. Records are keyed by the
+// string form of their ID, so IDs whose strings sort in creation order, such
+// as ULIDs and version 7 UUIDs, come back from FindAll in creation order.
+type ID interface {
+	String() string
+}
+
+// StringID is an ID that is already a string.
+type StringID string
+
+func (id StringID) String() string {
+	return string(id)
+}
+
+// Driver stores string values by namespace and ID. Every write bumps the
+// version of the record, which lets CompareAndSwap detect writes that happened
+// in between. A record that was never written has version 0.
+//
+// FindPage returns up to limit records ordered by ID, starting after the
+// cursor, along with the cursor for the next page. An empty cursor starts at
+// the beginning and an empty next cursor means there is nothing left. A limit
+// of 0 or less returns everything.
+//
+// Writes replace the index entries of the record with the given ones, and
+// FindIndex returns the records filed under a value of an index with a score
+// between min and max, ordered by score.
+//
+// A record written with a TTL, or given one with Expire, disappears once the
+// TTL runs out. A TTL of 0 means the record never expires, and writes without
+// a TTL clear any TTL the record had.
+//
+// Watch reports every write and delete in the namespace made through any
+// driver sharing the same backing store, until ctx is done. Records that
+// expire are not reported. The channel is closed when ctx is done, or early if
+// the watcher falls too far behind, after which it has to watch again and
+// catch up by reading.
+//
+// Commit applies a batch of writes, possibly across namespaces, atomically.
 type Driver interface {
 	FindAll(ctx context.Context, namespace string) (res []string, err error)
-	FindOne(ctx context.Context, namespace string, id ulid.ULID) (res string, err error)
-	UpsertOne(ctx context.Context, namespace string, id ulid.ULID, value string) error
-	DeleteOne(ctx context.Context, namespace string, id ulid.ULID) error
+	FindPage(ctx context.Context, namespace string, cursor string, limit int) (res []string, next string, err error)
+	FindOne(ctx context.Context, namespace string, id ID) (res string, err error)
+	FindOneVersioned(ctx context.Context, namespace string, id ID) (res string, version int64, err error)
+	FindIndex(ctx context.Context, namespace string, index string, value string, min float64, max float64) (res []string, err error)
+	UpsertOne(ctx context.Context, namespace string, id ID, value string, entries ...IndexEntry) error
+	UpsertOneTTL(ctx context.Context, namespace string, id ID, value string, ttl time.Duration, entries ...IndexEntry) error
+	CompareAndSwap(ctx context.Context, namespace string, id ID, version int64, value string, ttl time.Duration, entries ...IndexEntry) error
+	Expire(ctx context.Context, namespace string, id ID, ttl time.Duration) error
+	DeleteOne(ctx context.Context, namespace string, id ID) error
+	Watch(ctx context.Context, namespace string) (<-chan Change, error)
+	Commit(ctx context.Context, ops []Op) error
+}
+
+// NewDriver returns the driver selected by the config, behind a cache if one
+// is configured.
+func NewDriver(cfg config.Config) (Driver, error) {
+	var driver Driver
+	var err error
+	switch {
+	case cfg.RedisEnabled:
+		driver = NewRedisDriver(cfg)
+	case cfg.FileEnabled:
+		driver, err = NewFileDriver(cfg.FilePath)
+	case cfg.MemorySnapshotPath != "":
+		driver, err = NewMemDriverWithSnapshots(cfg.MemorySnapshotPath, cfg.MemorySnapshotInterval)
+	default:
+		driver = NewMemDriver()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.CacheSize > 0 {
+		return NewCacheDriver(driver, cfg.CacheSize, cfg.CacheTTL), nil
+	}
+
+	return driver, nil
 }
 
 type Client[T any] struct {
 	driver    Driver
 	namespace string
+	codec     Codec
+	schema    int
+	upgrade   func(t *T, schema int) error
+	indexers  []Indexer[T]
+	ttl       func(t T) time.Duration
+}
+
+type ClientOption func(o *clientOptions)
+
+type clientOptions struct {
+	codec  Codec
+	schema int
+}
+
+// WithCodec makes the client write records with codec rather than JSON.
+// It reads records written with codec as well as with any of the built-in
+// codecs.
+func WithCodec(codec Codec) ClientOption {
+	return func(o *clientOptions) {
+		o.codec = codec
+	}
+}
+
+// WithSchema stamps the records the client writes with the schema version of
+// T. See Client.WithUpgrade for reading records with older versions.
+func WithSchema(version int) ClientOption {
+	return func(o *clientOptions) {
+		o.schema = version
+	}
 }
 
 func (c *Client[T]) FindAll(ctx context.Context) (res []T, err error) {
-	data, err := c.driver.FindAll(ctx, c.namespace)
+	res, _, err = c.FindPage(ctx, "", 0)
+	return res, err
+}
+
+func (c *Client[T]) FindPage(ctx context.Context, cursor string, limit int) (res []T, next string, err error) {
+	data, next, err := c.driver.FindPage(ctx, c.namespace, cursor, limit)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	for _, d := range data {
-		var t T
-		err = json.Unmarshal([]byte(d), &t)
+		t, err := c.decode(d)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 		res = append(res, t)
 	}
 
-	return res, nil
+	return res, next, nil
 }
 
-func (c *Client[T]) FindOne(ctx context.Context, id ulid.ULID) (res T, err error) {
+// page picks the IDs of the requested page out of all the IDs in a namespace.
+// ids has to be sorted.
+func page(ids []string, cursor string, limit int) (res []string, next string) {
+	start, _ := slices.BinarySearch(ids, cursor)
+	if start < len(ids) && ids[start] == cursor {
+		start++
+	}
+
+	res = ids[start:]
+	if limit > 0 && len(res) > limit {
+		res = res[:limit]
+		next = res[limit-1]
+	}
+
+	return res, next
+}
+
+func (c *Client[T]) FindOne(ctx context.Context, id ID) (res T, err error) {
 	data, err := c.driver.FindOne(ctx, c.namespace, id)
 	if err != nil {
 		return res, err
 	}
 
-	err = json.Unmarshal([]byte(data), &res)
+	return c.decode(data)
+}
+
+// FindOneVersioned is FindOne that also returns the version of the record, to
+// be passed to CompareAndSwap.
+func (c *Client[T]) FindOneVersioned(ctx context.Context, id ID) (res T, version int64, err error) {
+	data, version, err := c.driver.FindOneVersioned(ctx, c.namespace, id)
 	if err != nil {
-		return res, err
+		return res, 0, err
+	}
+
+	res, err = c.decode(data)
+	if err != nil {
+		return res, 0, err
+	}
+
+	return res, version, nil
+}
+
+// FindIndex returns the records filed under value in the index, with a score
+// between min and max.
+func (c *Client[T]) FindIndex(ctx context.Context, index string, value string, min float64, max float64) (res []T, err error) {
+	data, err := c.driver.FindIndex(ctx, c.namespace, index, value, min, max)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range data {
+		t, err := c.decode(d)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, t)
 	}
 
 	return res, nil
 }
 
-func (c *Client[T]) UpsertOne(ctx context.Context, id ulid.ULID, value T) error {
-	encoded, err := json.Marshal(value)
+func (c *Client[T]) UpsertOne(ctx context.Context, id ID, value T) error {
+	encoded, err := encodeRecord(c.codec, c.schema, value)
 	if err != nil {
 		return err
 	}
 
-	err = c.driver.UpsertOne(ctx, c.namespace, id, string(encoded))
+	err = c.driver.UpsertOneTTL(ctx, c.namespace, id, encoded, c.ttlFor(value), c.entries(value)...)
 	if err != nil {
 		return err
 	}
@@ -65,7 +219,64 @@ func (c *Client[T]) UpsertOne(ctx context.Context, id ulid.ULID, value T) error
 	return nil
 }
 
-func (c *Client[T]) DeleteOne(ctx context.Context, id ulid.ULID) error {
+// CompareAndSwap saves value only if the record is still at the given
+// version, and returns constants.ErrorConflict otherwise.
+func (c *Client[T]) CompareAndSwap(ctx context.Context, id ID, version int64, value T) error {
+	encoded, err := encodeRecord(c.codec, c.schema, value)
+	if err != nil {
+		return err
+	}
+
+	err = c.driver.CompareAndSwap(ctx, c.namespace, id, version, encoded, c.ttlFor(value), c.entries(value)...)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// TxUpsert adds an upsert of the record to the transaction.
+func (c *Client[T]) TxUpsert(tx *Tx, id ID, value T) error {
+	return c.txWrite(tx, Op{ID: id}, value)
+}
+
+// TxCompareAndSwap adds an upsert of the record to the transaction that only
+// goes through if the record is still at the given version.
+func (c *Client[T]) TxCompareAndSwap(tx *Tx, id ID, version int64, value T) error {
+	return c.txWrite(tx, Op{ID: id, CheckVersion: true, Version: version}, value)
+}
+
+// TxDelete adds a delete of the record to the transaction.
+func (c *Client[T]) TxDelete(tx *Tx, id ID) error {
+	return tx.add(c.driver, Op{Type: ChangeDelete, Namespace: c.namespace, ID: id})
+}
+
+// TxCompareAndDelete adds a delete of the record to the transaction that only
+// goes through if the record is still at the given version.
+func (c *Client[T]) TxCompareAndDelete(tx *Tx, id ID, version int64) error {
+	return tx.add(c.driver, Op{Type: ChangeDelete, Namespace: c.namespace, ID: id, CheckVersion: true, Version: version})
+}
+
+func (c *Client[T]) txWrite(tx *Tx, op Op, value T) error {
+	encoded, err := encodeRecord(c.codec, c.schema, value)
+	if err != nil {
+		return err
+	}
+
+	op.Type = ChangeUpsert
+	op.Namespace = c.namespace
+	op.Value = encoded
+	op.TTL = c.ttlFor(value)
+	op.Entries = c.entries(value)
+
+	return tx.add(c.driver, op)
+}
+
+func (c *Client[T]) Expire(ctx context.Context, id ID, ttl time.Duration) error {
+	return c.driver.Expire(ctx, c.namespace, id, ttl)
+}
+
+func (c *Client[T]) DeleteOne(ctx context.Context, id ID) error {
 	err := c.driver.DeleteOne(ctx, c.namespace, id)
 	if err != nil {
 		return err
@@ -74,9 +285,106 @@ func (c *Client[T]) DeleteOne(ctx context.Context, id ulid.ULID) error {
 	return nil
 }
 
-func NewClient[T any](driver Driver, namespace string) *Client[T] {
+// decode reads a record written with any codec, upgrading it if it was
+// written with an older schema.
+func (c *Client[T]) decode(data string) (res T, err error) {
+	schema, err := decodeRecord(data, &res, c.codec)
+	if err != nil {
+		return res, err
+	}
+
+	if schema < c.schema && c.upgrade != nil {
+		if err = c.upgrade(&res, schema); err != nil {
+			return res, errors.Wrapf(err, "upgrade record from schema %d", schema)
+		}
+	}
+
+	return res, nil
+}
+
+// Watch is Driver.Watch with the values decoded.
+func (c *Client[T]) Watch(ctx context.Context) (<-chan TypedChange[T], error) {
+	changes, err := c.driver.Watch(ctx, c.namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(chan TypedChange[T])
+	go func() {
+		defer close(res)
+		for change := range changes {
+			tc := TypedChange[T]{Type: change.Type, ID: change.ID}
+			if change.Type == ChangeUpsert {
+				tc.Value, tc.Err = c.decode(change.Value)
+			}
+
+			select {
+			case res <- tc:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return res, nil
+}
+
+func (c *Client[T]) entries(value T) (res []IndexEntry) {
+	for _, indexer := range c.indexers {
+		res = append(res, indexer(value)...)
+	}
+
+	return res
+}
+
+func (c *Client[T]) ttlFor(value T) time.Duration {
+	if c.ttl == nil {
+		return 0
+	}
+
+	return c.ttl(value)
+}
+
+// WithIndexers returns a copy of the client that maintains the given indexes
+// as well.
+func (c *Client[T]) WithIndexers(indexers ...Indexer[T]) *Client[T] {
+	res := *c
+	res.indexers = append(slices.Clone(c.indexers), indexers...)
+
+	return &res
+}
+
+// WithUpgrade returns a copy of the client that runs upgrade on every record
+// written with an older schema version than the client's as it is read. The
+// record is decoded into T as well as it can be first.
+func (c *Client[T]) WithUpgrade(upgrade func(t *T, schema int) error) *Client[T] {
+	res := *c
+	res.upgrade = upgrade
+
+	return &res
+}
+
+// WithTTL returns a copy of the client that gives every record it writes the
+// TTL ttl picks for it.
+func (c *Client[T]) WithTTL(ttl func(t T) time.Duration) *Client[T] {
+	res := *c
+	res.ttl = ttl
+
+	return &res
+}
+
+func NewClient[T any](driver Driver, namespace string, opts ...ClientOption) *Client[T] {
+	o := clientOptions{
+		codec: JSONCodec,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	return &Client[T]{
 		driver:    driver,
 		namespace: namespace,
+		codec:     o.codec,
+		schema:    o.schema,
 	}
 }
@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Codec turns records into bytes and back.
+type Codec interface {
+	Name() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+var (
+	JSONCodec     Codec = jsonCodec{}
+	GobCodec      Codec = gobCodec{}
+	GzipJSONCodec Codec = gzipCodec{codec: jsonCodec{}}
+)
+
+var codecs = map[string]Codec{
+	JSONCodec.Name():     JSONCodec,
+	GobCodec.Name():      GobCodec,
+	GzipJSONCodec.Name(): GzipJSONCodec,
+}
+
+// CodecByName returns the built-in codec with the given name.
+func CodecByName(name string) (Codec, error) {
+	c, ok := codecs[name]
+	if !ok {
+		return nil, errors.Errorf("unknown codec %q", name)
+	}
+
+	return c, nil
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string {
+	return "json"
+}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Name() string {
+	return "gob"
+}
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// gzipCodec compresses what another codec produces.
+type gzipCodec struct {
+	codec Codec
+}
+
+func (c gzipCodec) Name() string {
+	return c.codec.Name() + "+gzip"
+}
+
+func (c gzipCodec) Marshal(v any) ([]byte, error) {
+	data, err := c.codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err = w.Write(data); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (c gzipCodec) Unmarshal(data []byte, v any) error {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	data, err = io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	return c.codec.Unmarshal(data, v)
+}
+
+// envelope is how records are stored: the encoded record along with the codec
+// and schema version that wrote it, so records written different ways can be
+// read side by side. JSON goes in Raw as is so it stays readable; everything
+// else goes in Data. The field names can't clash with those of a Go struct,
+// which is how records from before envelopes are told apart.
+type envelope struct {
+	Codec  string          `json:"$codec"`
+	Schema int             `json:"$schema"`
+	Raw    json.RawMessage `json:"$raw,omitempty"`
+	Data   []byte          `json:"$data,omitempty"`
+}
+
+func encodeRecord(codec Codec, schema int, v any) (string, error) {
+	data, err := codec.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	e := envelope{Codec: codec.Name(), Schema: schema}
+	if codec == JSONCodec {
+		e.Raw = data
+	} else {
+		e.Data = data
+	}
+
+	encoded, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+
+	return string(encoded), nil
+}
+
+// decodeRecord decodes a record into v and returns the schema version it was
+// written with. Records from before envelopes are plain JSON at schema 0.
+// Records are decoded with own if it wrote them, and with the built-in codec
+// that did otherwise, so a custom codec can read back its own records.
+func decodeRecord(data string, v any, own Codec) (schema int, err error) {
+	var e envelope
+	if err = json.Unmarshal([]byte(data), &e); err != nil || e.Codec == "" {
+		return 0, json.Unmarshal([]byte(data), v)
+	}
+
+	codec := own
+	if codec == nil || codec.Name() != e.Codec {
+		if codec, err = CodecByName(e.Codec); err != nil {
+			return 0, err
+		}
+	}
+
+	payload := e.Data
+	if e.Raw != nil {
+		payload = e.Raw
+	}
+	if err = codec.Unmarshal(payload, v); err != nil {
+		return 0, errors.Wrapf(err, "decode %s record", e.Codec)
+	}
+
+	return e.Schema, nil
+}
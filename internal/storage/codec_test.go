@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testRecord struct {
+	ID        ulid.ULID
+	Name      string
+	Counts    map[string]int
+	Moves     []string
+	CreatedAt time.Time
+}
+
+func newTestRecord() testRecord {
+	return testRecord{
+		ID:        ulid.Make(),
+		Name:      "thing",
+		Counts:    map[string]int{"a": 1, "b": 2},
+		Moves:     []string{"one", "two"},
+		CreatedAt: time.Date(2025, 4, 7, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestCodecs(t *testing.T) {
+	tests := []struct {
+		name  string
+		codec Codec
+	}{
+		{
+			name:  "json",
+			codec: JSONCodec,
+		},
+		{
+			name:  "gob",
+			codec: GobCodec,
+		},
+		{
+			name:  "json+gzip",
+			codec: GzipJSONCodec,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expected := newTestRecord()
+
+			encoded, err := encodeRecord(tt.codec, 2, expected)
+			require.NoError(t, err)
+
+			var output testRecord
+			schema, err := decodeRecord(encoded, &output, nil)
+			require.NoError(t, err)
+
+			assert.Equal(t, 2, schema)
+			assert.Equal(t, expected, output)
+
+			codec, err := CodecByName(tt.name)
+			require.NoError(t, err)
+			assert.Equal(t, tt.codec, codec)
+		})
+	}
+}
+
+func TestCodecByName(t *testing.T) {
+	_, err := CodecByName("xml")
+	assert.Error(t, err)
+}
+
+func Test_decodeRecord_legacy(t *testing.T) {
+	expected := newTestRecord()
+	encoded, err := json.Marshal(expected)
+	require.NoError(t, err)
+
+	var output testRecord
+	schema, err := decodeRecord(string(encoded), &output, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, 0, schema)
+	assert.Equal(t, expected, output)
+}
+
+func TestClient_mixedCodecs(t *testing.T) {
+	ctx := context.Background()
+	driver := NewMemDriver()
+
+	legacy := newTestRecord()
+	encoded, err := json.Marshal(legacy)
+	require.NoError(t, err)
+	require.NoError(t, driver.UpsertOne(ctx, "test", legacy.ID, string(encoded)))
+
+	gobbed := newTestRecord()
+	require.NoError(t, NewClient[testRecord](driver, "test", WithCodec(GobCodec), WithSchema(1)).UpsertOne(ctx, gobbed.ID, gobbed))
+
+	var upgraded []int
+	client := NewClient[testRecord](driver, "test", WithCodec(GzipJSONCodec), WithSchema(2)).WithUpgrade(func(r *testRecord, schema int) error {
+		upgraded = append(upgraded, schema)
+		r.Name += " upgraded"
+		return nil
+	})
+
+	current := newTestRecord()
+	require.NoError(t, client.UpsertOne(ctx, current.ID, current))
+
+	output, err := client.FindAll(ctx)
+	require.NoError(t, err)
+	require.Len(t, output, 3)
+
+	assert.Equal(t, "thing upgraded", output[0].Name)
+	assert.Equal(t, "thing upgraded", output[1].Name)
+	assert.Equal(t, "thing", output[2].Name)
+	assert.Equal(t, []int{0, 1}, upgraded)
+}
+
+func TestClient_customCodec(t *testing.T) {
+	ctx := context.Background()
+	driver := NewMemDriver()
+	codec := gzipCodec{codec: GobCodec}
+	_, err := CodecByName(codec.Name())
+	require.Error(t, err)
+
+	expected := newTestRecord()
+	client := NewClient[testRecord](driver, "test", WithCodec(codec))
+	require.NoError(t, client.UpsertOne(ctx, expected.ID, expected))
+
+	output, err := client.FindOne(ctx, expected.ID)
+	require.NoError(t, err)
+	assert.Equal(t, expected, output)
+
+	// Clients without the codec cannot make sense of the record.
+	_, err = NewClient[testRecord](driver, "test").FindOne(ctx, expected.ID)
+	assert.Error(t, err)
+}
@@ -0,0 +1,399 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// compactInterval is how often FileDriver checks whether its log is due
+	// for compaction.
+	compactInterval = 10 * time.Minute
+	// compactMinEntries is how long the log has to get before it is
+	// compacted, however much of it is stale.
+	compactMinEntries = 1000
+
+	opExpire ChangeType = "expire"
+)
+
+// FileDriver keeps everything in a MemDriver and makes it last by appending
+// every write to a log file, which is replayed when the driver is created.
+// Each write is synced to disk before it is applied, so a write that returned
+// survives a crash. A write that was cut short by a crash is dropped when the
+// log is replayed.
+//
+// The log only grows, so every now and then it is compacted: rewritten with
+// just the records that are left, and swapped in for the old one in one go.
+//
+// Only one driver can use a log at a time, and Watch only reports writes made
+// through it.
+type FileDriver struct {
+	mem  *MemDriver
+	path string
+	// file, size and logged are guarded by the lock of mem. logged counts the
+	// entries in the log and live the records there were when it was last
+	// rewritten.
+	file   *os.File
+	size   int64
+	logged int
+	live   int
+	// failed is set if the log was swapped out but could not be reopened.
+	// Nothing can be written after that.
+	failed error
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// logEntry is a line of the log: either a batch of writes committed together
+// or a record as it was when the log was compacted.
+type logEntry struct {
+	Ops    []logOp `json:",omitempty"`
+	Record *record `json:",omitempty"`
+}
+
+// logOp is an upsert, delete or expire. Expiry times are absolute, so they
+// hold up across restarts.
+type logOp struct {
+	Type      ChangeType
+	Namespace string
+	ID        string
+	Value     string       `json:",omitempty"`
+	ExpiresAt time.Time    `json:",omitzero"`
+	Entries   []IndexEntry `json:",omitempty"`
+}
+
+func (d *FileDriver) FindAll(ctx context.Context, namespace string) (res []string, err error) {
+	return d.mem.FindAll(ctx, namespace)
+}
+
+func (d *FileDriver) FindPage(ctx context.Context, namespace string, cursor string, limit int) (res []string, next string, err error) {
+	return d.mem.FindPage(ctx, namespace, cursor, limit)
+}
+
+func (d *FileDriver) FindOne(ctx context.Context, namespace string, id ID) (res string, err error) {
+	return d.mem.FindOne(ctx, namespace, id)
+}
+
+func (d *FileDriver) FindOneVersioned(ctx context.Context, namespace string, id ID) (res string, version int64, err error) {
+	return d.mem.FindOneVersioned(ctx, namespace, id)
+}
+
+func (d *FileDriver) FindIndex(ctx context.Context, namespace string, index string, value string, min float64, max float64) (res []string, err error) {
+	return d.mem.FindIndex(ctx, namespace, index, value, min, max)
+}
+
+func (d *FileDriver) UpsertOne(ctx context.Context, namespace string, id ID, value string, entries ...IndexEntry) error {
+	return d.UpsertOneTTL(ctx, namespace, id, value, 0, entries...)
+}
+
+func (d *FileDriver) UpsertOneTTL(ctx context.Context, namespace string, id ID, value string, ttl time.Duration, entries ...IndexEntry) error {
+	return d.Commit(ctx, []Op{{Type: ChangeUpsert, Namespace: namespace, ID: id, Value: value, TTL: ttl, Entries: entries}})
+}
+
+func (d *FileDriver) CompareAndSwap(ctx context.Context, namespace string, id ID, version int64, value string, ttl time.Duration, entries ...IndexEntry) error {
+	return d.Commit(ctx, []Op{{Type: ChangeUpsert, Namespace: namespace, ID: id, Value: value, TTL: ttl, Entries: entries, CheckVersion: true, Version: version}})
+}
+
+func (d *FileDriver) DeleteOne(ctx context.Context, namespace string, id ID) error {
+	return d.Commit(ctx, []Op{{Type: ChangeDelete, Namespace: namespace, ID: id}})
+}
+
+// Commit logs the writes as a single entry once their versions check out, and
+// only then applies them.
+func (d *FileDriver) Commit(ctx context.Context, ops []Op) error {
+	return d.mem.commit(ops, func() error {
+		now := d.mem.clock()
+		entry := logEntry{Ops: make([]logOp, len(ops))}
+		for i, op := range ops {
+			entry.Ops[i] = logOp{
+				Type:      op.Type,
+				Namespace: op.Namespace,
+				ID:        op.ID.String(),
+				Value:     op.Value,
+				ExpiresAt: expiresAt(now, op.TTL),
+				Entries:   op.Entries,
+			}
+		}
+		return d.append(entry)
+	})
+}
+
+func (d *FileDriver) Expire(ctx context.Context, namespace string, id ID, ttl time.Duration) error {
+	return d.mem.expire(namespace, id, ttl, func() error {
+		return d.append(logEntry{Ops: []logOp{{
+			Type:      opExpire,
+			Namespace: namespace,
+			ID:        id.String(),
+			ExpiresAt: expiresAt(d.mem.clock(), ttl),
+		}}})
+	})
+}
+
+func (d *FileDriver) Watch(ctx context.Context, namespace string) (<-chan Change, error) {
+	return d.mem.Watch(ctx, namespace)
+}
+
+func expiresAt(now time.Time, ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(ttl).UTC()
+}
+
+// append writes the entry to the end of the log and syncs it. If that fails,
+// whatever part of the entry made it is cut off again. The lock of mem must be
+// held.
+func (d *FileDriver) append(entry logEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return errors.Wrap(err, "encode log entry")
+	}
+	line = append(line, '\n')
+
+	if d.failed != nil {
+		return d.failed
+	}
+	if _, err = d.file.Write(line); err == nil {
+		err = d.file.Sync()
+	}
+	if err != nil {
+		_ = d.file.Truncate(d.size)
+		return errors.Wrap(err, "write log")
+	}
+	d.size += int64(len(line))
+	d.logged++
+
+	return nil
+}
+
+// replay applies the log to mem. A last line that is cut short or garbled was
+// being written when the process died, so it is dropped and the log truncated
+// before it. Anything wrong before the last line is an error.
+//
+// Records are only dropped for having expired once the whole log has been
+// applied, since a later entry may have kept them from expiring.
+func (d *FileDriver) replay(r io.Reader) error {
+	err := d.replayEntries(r)
+	d.mem.sweep()
+
+	return err
+}
+
+func (d *FileDriver) replayEntries(r io.Reader) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if errors.Is(err, io.EOF) && len(line) == 0 {
+			return nil
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return errors.Wrap(err, "read log")
+		}
+
+		var entry logEntry
+		complete := bytes.HasSuffix(line, []byte("\n"))
+		if !complete || json.Unmarshal(line, &entry) != nil {
+			if _, peekErr := br.Peek(1); !errors.Is(peekErr, io.EOF) {
+				return errors.Errorf("corrupt log entry at offset %d", d.size)
+			}
+			return errors.Wrap(d.file.Truncate(d.size), "truncate log")
+		}
+
+		if err = d.apply(entry); err != nil {
+			return err
+		}
+		d.size += int64(len(line))
+		d.logged++
+	}
+}
+
+// apply applies an entry the way it was applied when it was written, with the
+// expiry times it was given then, whether or not they have passed since.
+func (d *FileDriver) apply(entry logEntry) error {
+	d.mem.m.Lock()
+	defer d.mem.m.Unlock()
+
+	if entry.Record != nil {
+		d.mem.put(*entry.Record)
+		return nil
+	}
+
+	for _, lo := range entry.Ops {
+		id := StringID(lo.ID)
+		switch lo.Type {
+		case ChangeUpsert:
+			d.mem.write(lo.Namespace, id, lo.Value, 0, lo.Entries)
+			d.mem.setExpiry(lo.Namespace, id, lo.ExpiresAt)
+		case ChangeDelete:
+			d.mem.delete(lo.Namespace, id)
+		case opExpire:
+			if _, ok := d.mem.items[d.mem.generateKey(lo.Namespace, id)]; ok {
+				d.mem.setExpiry(lo.Namespace, id, lo.ExpiresAt)
+			}
+		default:
+			return errors.Errorf("unknown log op %q", lo.Type)
+		}
+	}
+
+	return nil
+}
+
+// Compact rewrites the log with only the records that are left, and swaps it
+// in for the old one.
+func (d *FileDriver) Compact() error {
+	return d.compact(true)
+}
+
+// compact rewrites the log if forced, or if it has grown to more than twice
+// the entries it needs.
+func (d *FileDriver) compact(force bool) error {
+	return d.mem.records(func(records []record) error {
+		if !force && (d.logged < compactMinEntries || d.logged <= 2*d.live) {
+			return nil
+		}
+
+		tmp := d.path + ".tmp"
+		size, err := writeLog(tmp, records)
+		if err != nil {
+			_ = os.Remove(tmp)
+			return err
+		}
+		if err = os.Rename(tmp, d.path); err != nil {
+			return errors.Wrap(err, "replace log")
+		}
+
+		// The old log is gone from path now, so writing on to it would lose
+		// everything written. If the new one cannot be opened, the driver
+		// stops taking writes.
+		file, err := openLog(d.path)
+		if err != nil {
+			d.failed = errors.Wrap(err, "reopen compacted log")
+			return d.failed
+		}
+		_ = d.file.Close()
+		d.file = file
+		d.size = size
+		d.logged = len(records)
+		d.live = len(records)
+
+		return syncDir(filepath.Dir(d.path))
+	})
+}
+
+// writeLog writes a log holding the records to path and syncs it.
+func writeLog(path string, records []record) (size int64, err error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return 0, errors.Wrap(err, "create log")
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	enc := json.NewEncoder(w)
+	for _, r := range records {
+		if err = enc.Encode(logEntry{Record: &r}); err != nil {
+			return 0, errors.Wrap(err, "write log")
+		}
+	}
+	if err = w.Flush(); err != nil {
+		return 0, errors.Wrap(err, "write log")
+	}
+	if err = file.Sync(); err != nil {
+		return 0, errors.Wrap(err, "sync log")
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, errors.Wrap(err, "stat log")
+	}
+
+	return info.Size(), nil
+}
+
+func openLog(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, errors.Wrap(err, "open log")
+	}
+
+	return file, nil
+}
+
+// syncDir makes a rename in dir stick.
+func syncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return errors.Wrap(err, "open log directory")
+	}
+	defer f.Close()
+
+	return errors.Wrap(f.Sync(), "sync log directory")
+}
+
+func (d *FileDriver) runCompactor() {
+	ticker := time.NewTicker(compactInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// A failed compaction leaves the old log in place, so there is
+			// nothing to do but try again next time.
+			_ = d.compact(false)
+		case <-d.done:
+			return
+		}
+	}
+}
+
+// Close stops the compactor and closes the log.
+func (d *FileDriver) Close() error {
+	var err error
+	d.closeOnce.Do(func() {
+		close(d.done)
+		_ = d.mem.Close()
+
+		d.mem.m.Lock()
+		defer d.mem.m.Unlock()
+		err = d.file.Close()
+	})
+
+	return err
+}
+
+// NewFileDriver opens the log at path, creating it if need be, and replays
+// it.
+func NewFileDriver(path string) (*FileDriver, error) {
+	file, err := openLog(path)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &FileDriver{
+		mem:  NewMemDriver(),
+		path: path,
+		file: file,
+		done: make(chan struct{}),
+	}
+	if err = d.replay(file); err != nil {
+		_ = file.Close()
+		return nil, errors.Wrapf(err, "replay %s", path)
+	}
+	_ = d.mem.records(func(records []record) error {
+		d.live = len(records)
+		return nil
+	})
+	go d.runCompactor()
+
+	return d, nil
+}
@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/rBurgett/scmsh/internal/config"
+	"github.com/rBurgett/scmsh/internal/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestFileDriver(t *testing.T, path string) *FileDriver {
+	d, err := NewFileDriver(path)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = d.Close()
+	})
+
+	return d
+}
+
+func TestFileDriver(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.log")
+	d := newTestFileDriver(t, path)
+	id1 := ulid.Make()
+	id2 := ulid.Make()
+	id3 := ulid.Make()
+
+	require.NoError(t, d.UpsertOne(ctx, "a", id1, `{"some":"thing1"}`, IndexEntry{Index: "i", Value: "x", Score: 2}))
+	require.NoError(t, d.CompareAndSwap(ctx, "a", id1, 1, `{"some":"thing2"}`, 0, IndexEntry{Index: "i", Value: "x", Score: 3}))
+	assert.ErrorIs(t, d.CompareAndSwap(ctx, "a", id1, 1, `{"some":"thing3"}`, 0), constants.ErrorConflict)
+	require.NoError(t, d.Commit(ctx, []Op{
+		{Type: ChangeUpsert, Namespace: "a", ID: id2, Value: `{"some":"thing4"}`, Entries: []IndexEntry{{Index: "i", Value: "x", Score: 1}}},
+		{Type: ChangeUpsert, Namespace: "b", ID: id3, Value: `{"some":"thing5"}`},
+	}))
+	require.NoError(t, d.DeleteOne(ctx, "b", id3))
+	require.NoError(t, d.Close())
+
+	d = newTestFileDriver(t, path)
+
+	data, version, err := d.FindOneVersioned(ctx, "a", id1)
+	require.NoError(t, err)
+	assert.Equal(t, `{"some":"thing2"}`, data)
+	assert.Equal(t, int64(2), version)
+
+	res, err := d.FindIndex(ctx, "a", "i", "x", MinScore, MaxScore)
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"some":"thing4"}`, `{"some":"thing2"}`}, res)
+
+	_, err = d.FindOne(ctx, "b", id3)
+	assert.ErrorIs(t, err, constants.ErrorNotFound)
+
+	require.NoError(t, d.CompareAndSwap(ctx, "a", id1, 2, `{"some":"thing3"}`, 0))
+}
+
+func TestFileDriver_ttl(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.log")
+	d := newTestFileDriver(t, path)
+	id1 := ulid.Make()
+	id2 := ulid.Make()
+	id3 := ulid.Make()
+	// Given a TTL that would have run out by now, and then kept from
+	// expiring.
+	extended := ulid.Make()
+	persisted := ulid.Make()
+	rewritten := ulid.Make()
+
+	require.NoError(t, d.UpsertOneTTL(ctx, "test", id1, `{"some":"thing1"}`, 50*time.Millisecond))
+	require.NoError(t, d.UpsertOneTTL(ctx, "test", id2, `{"some":"thing2"}`, time.Hour))
+	require.NoError(t, d.UpsertOne(ctx, "test", id3, `{"some":"thing3"}`))
+	require.NoError(t, d.Expire(ctx, "test", id3, 50*time.Millisecond))
+	for _, id := range []ulid.ULID{extended, persisted, rewritten} {
+		require.NoError(t, d.UpsertOneTTL(ctx, "test", id, `{"some":"thing"}`, 50*time.Millisecond))
+	}
+	require.NoError(t, d.Expire(ctx, "test", extended, time.Hour))
+	require.NoError(t, d.Expire(ctx, "test", persisted, 0))
+	require.NoError(t, d.UpsertOne(ctx, "test", rewritten, `{"some":"thing"}`))
+	require.NoError(t, d.Close())
+
+	time.Sleep(60 * time.Millisecond)
+	d = newTestFileDriver(t, path)
+
+	_, err := d.FindOne(ctx, "test", id1)
+	assert.ErrorIs(t, err, constants.ErrorNotFound)
+	_, err = d.FindOne(ctx, "test", id2)
+	assert.NoError(t, err)
+	_, err = d.FindOne(ctx, "test", id3)
+	assert.ErrorIs(t, err, constants.ErrorNotFound)
+	for _, id := range []ulid.ULID{extended, persisted, rewritten} {
+		_, err = d.FindOne(ctx, "test", id)
+		assert.NoError(t, err)
+	}
+}
+
+func TestFileDriver_tornWrite(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.log")
+	d := newTestFileDriver(t, path)
+	id1 := ulid.Make()
+	id2 := ulid.Make()
+
+	require.NoError(t, d.UpsertOne(ctx, "test", id1, `{"some":"thing1"}`))
+	require.NoError(t, d.Close())
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"Ops":[{"Type":"upsert","Namespace":"test","ID":"`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	d = newTestFileDriver(t, path)
+	_, err = d.FindOne(ctx, "test", id1)
+	require.NoError(t, err)
+	require.NoError(t, d.UpsertOne(ctx, "test", id2, `{"some":"thing2"}`))
+	require.NoError(t, d.Close())
+
+	d = newTestFileDriver(t, path)
+	res, err := d.FindAll(ctx, "test")
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"some":"thing1"}`, `{"some":"thing2"}`}, res)
+}
+
+func TestFileDriver_corrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	require.NoError(t, os.WriteFile(path, []byte("garbage\n{}\n"), 0o600))
+
+	_, err := NewFileDriver(path)
+	assert.ErrorContains(t, err, "corrupt log entry at offset 0")
+}
+
+func TestFileDriver_Compact(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.log")
+	d := newTestFileDriver(t, path)
+	id1 := ulid.Make()
+	id2 := ulid.Make()
+
+	for i := range 10 {
+		require.NoError(t, d.UpsertOne(ctx, "test", id1, strings.Repeat("x", i+1), IndexEntry{Index: "i", Value: "x"}))
+		require.NoError(t, d.UpsertOne(ctx, "test", id2, "deleted"))
+		require.NoError(t, d.DeleteOne(ctx, "test", id2))
+	}
+	before, err := os.Stat(path)
+	require.NoError(t, err)
+
+	require.NoError(t, d.Compact())
+	after, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Less(t, after.Size(), before.Size())
+	assert.Equal(t, 1, d.logged)
+
+	require.NoError(t, d.UpsertOne(ctx, "test", id2, "new"))
+	require.NoError(t, d.Close())
+
+	d = newTestFileDriver(t, path)
+	data, version, err := d.FindOneVersioned(ctx, "test", id1)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("x", 10), data)
+	assert.Equal(t, int64(10), version)
+	res, err := d.FindIndex(ctx, "test", "i", "x", MinScore, MaxScore)
+	require.NoError(t, err)
+	assert.Equal(t, []string{strings.Repeat("x", 10)}, res)
+	data, err = d.FindOne(ctx, "test", id2)
+	require.NoError(t, err)
+	assert.Equal(t, "new", data)
+}
+
+func TestNewDriver(t *testing.T) {
+	driver, err := NewDriver(config.Config{})
+	require.NoError(t, err)
+	assert.IsType(t, &MemDriver{}, driver)
+
+	driver, err = NewDriver(config.Config{FileEnabled: true, FilePath: filepath.Join(t.TempDir(), "test.log")})
+	require.NoError(t, err)
+	assert.IsType(t, &FileDriver{}, driver)
+	require.NoError(t, driver.(*FileDriver).Close())
+
+	_, err = NewDriver(config.Config{FileEnabled: true, FilePath: filepath.Join(t.TempDir(), "missing", "test.log")})
+	assert.Error(t, err)
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+)
+
+// IndexEntry files a record under Value in the named index. Records under the
+// same value are ordered by Score.
+type IndexEntry struct {
+	Index string
+	Value string
+	Score float64
+}
+
+// Indexer lists the index entries of a record. Drivers replace a record's
+// entries with the new ones on every write and drop them on delete, so the
+// indexes never disagree with the records themselves.
+type Indexer[T any] func(t T) []IndexEntry
+
+var (
+	MinScore = math.Inf(-1)
+	MaxScore = math.Inf(1)
+)
+
+func indexKey(namespace string, index string, value string) string {
+	return fmt.Sprintf("%s.index:%s:%s", namespace, index, value)
+}
+
+// scoreArg formats a score the way Redis range queries expect it.
+func scoreArg(score float64) string {
+	switch {
+	case math.IsInf(score, -1):
+		return "-inf"
+	case math.IsInf(score, 1):
+		return "+inf"
+	default:
+		return strconv.FormatFloat(score, 'f', -1, 64)
+	}
+}
+
+type scoredID struct {
+	id    string
+	score float64
+}
+
+// sortScored orders IDs the same way Redis orders sorted set members: by
+// score, then by ID.
+func sortScored(ids []scoredID) {
+	slices.SortFunc(ids, func(a, b scoredID) int {
+		if c := cmp.Compare(a.score, b.score); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.id, b.id)
+	})
+}
@@ -1,84 +1,560 @@
 package storage
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
-	"github.com/oklog/ulid/v2"
+	"github.com/pkg/errors"
 	"github.com/rBurgett/scmsh/internal/constants"
 )
 
+// sweepInterval is how often MemDriver clears out expired items once it has
+// been given one.
+const sweepInterval = time.Minute
+
 type MemDriver struct {
-	m     sync.RWMutex
-	items map[string]string
+	m        sync.RWMutex
+	items    map[string]string
+	versions map[string]int64
+	// indexes maps an index key to the IDs filed under it and their scores,
+	// and entries remembers the index entries of each item so they can be
+	// replaced.
+	indexes map[string]map[string]float64
+	entries map[string][]IndexEntry
+	// expiries holds when items with a TTL expire. Expired items are
+	// invisible straight away and removed by the sweeper later.
+	expiries map[string]expiry
+	now      func() time.Time
+	sweeper  sync.Once
+	done     chan struct{}
+	watchers map[string]map[chan Change]struct{}
+	// snapshotPath is where the items are saved on Close, if anywhere.
+	// snapshotM keeps snapshots from writing over each other, or an older
+	// one from landing after a newer one.
+	snapshotPath string
+	snapshotM    sync.Mutex
+}
+
+type expiry struct {
+	namespace string
+	id        ID
+	at        time.Time
 }
 
-func (d *MemDriver) generateKey(namespace string, id ulid.ULID) string {
+func (d *MemDriver) generateKey(namespace string, id ID) string {
 	return fmt.Sprintf("%s:%s", namespace, id)
 }
 
 func (d *MemDriver) FindAll(ctx context.Context, namespace string) (res []string, err error) {
+	res, _, err = d.FindPage(ctx, namespace, "", 0)
+	return res, err
+}
+
+func (d *MemDriver) FindPage(ctx context.Context, namespace string, cursor string, limit int) (res []string, next string, err error) {
 	d.m.RLock()
 	defer d.m.RUnlock()
 
-	type kv struct {
-		k string
-		v string
-	}
-	var items []kv
-	for k, v := range d.items {
-		if strings.HasPrefix(k, fmt.Sprintf("%s:", namespace)) {
-			items = append(items, kv{k, v})
+	prefix := fmt.Sprintf("%s:", namespace)
+	var ids []string
+	for k := range d.items {
+		if id, ok := strings.CutPrefix(k, prefix); ok && !d.expired(k) {
+			ids = append(ids, id)
 		}
 	}
+	sort.Strings(ids)
 
-	sort.Slice(items, func(i, j int) bool {
-		return items[i].k < items[j].k
-	})
-
-	for _, i := range items {
-		res = append(res, i.v)
+	ids, next = page(ids, cursor, limit)
+	for _, id := range ids {
+		res = append(res, d.items[prefix+id])
 	}
 
-	return res, nil
+	return res, next, nil
+}
+
+func (d *MemDriver) FindOne(ctx context.Context, namespace string, id ID) (res string, err error) {
+	res, _, err = d.FindOneVersioned(ctx, namespace, id)
+	return res, err
 }
 
-func (d *MemDriver) FindOne(ctx context.Context, namespace string, id ulid.ULID) (res string, err error) {
+func (d *MemDriver) FindOneVersioned(ctx context.Context, namespace string, id ID) (res string, version int64, err error) {
 	d.m.RLock()
 	defer d.m.RUnlock()
 
 	key := d.generateKey(namespace, id)
 	res, ok := d.items[key]
-	if !ok {
-		return "", constants.ErrorNotFound
+	if !ok || d.expired(key) {
+		return "", 0, constants.ErrorNotFound
+	}
+
+	return res, d.versions[key], nil
+}
+
+func (d *MemDriver) FindIndex(ctx context.Context, namespace string, index string, value string, min float64, max float64) (res []string, err error) {
+	d.m.RLock()
+	defer d.m.RUnlock()
+
+	var ids []scoredID
+	for id, score := range d.indexes[indexKey(namespace, index, value)] {
+		if score >= min && score <= max && !d.expired(fmt.Sprintf("%s:%s", namespace, id)) {
+			ids = append(ids, scoredID{id: id, score: score})
+		}
+	}
+	sortScored(ids)
+
+	for _, i := range ids {
+		res = append(res, d.items[fmt.Sprintf("%s:%s", namespace, i.id)])
 	}
 
 	return res, nil
 }
 
-func (d *MemDriver) UpsertOne(ctx context.Context, namespace string, id ulid.ULID, value string) (err error) {
+func (d *MemDriver) UpsertOne(ctx context.Context, namespace string, id ID, value string, entries ...IndexEntry) error {
+	return d.UpsertOneTTL(ctx, namespace, id, value, 0, entries...)
+}
+
+func (d *MemDriver) UpsertOneTTL(ctx context.Context, namespace string, id ID, value string, ttl time.Duration, entries ...IndexEntry) error {
+	return d.Commit(ctx, []Op{{Type: ChangeUpsert, Namespace: namespace, ID: id, Value: value, TTL: ttl, Entries: entries}})
+}
+
+func (d *MemDriver) CompareAndSwap(ctx context.Context, namespace string, id ID, version int64, value string, ttl time.Duration, entries ...IndexEntry) error {
+	return d.Commit(ctx, []Op{{Type: ChangeUpsert, Namespace: namespace, ID: id, Value: value, TTL: ttl, Entries: entries, CheckVersion: true, Version: version}})
+}
+
+// Commit applies the writes under a single hold of the lock, after checking
+// every version, so nobody ever sees part of a transaction.
+func (d *MemDriver) Commit(ctx context.Context, ops []Op) error {
+	return d.commit(ops, nil)
+}
+
+// commit is Commit with a hook that runs once the versions have been checked,
+// with the lock held. Nothing is applied if before fails.
+func (d *MemDriver) commit(ops []Op, before func() error) error {
+	if err := checkOps(ops); err != nil {
+		return err
+	}
+
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	for _, op := range ops {
+		d.evictIfExpired(op.Namespace, op.ID)
+		if op.CheckVersion && d.versions[op.key()] != op.Version {
+			return constants.ErrorConflict
+		}
+	}
+	if before != nil {
+		if err := before(); err != nil {
+			return err
+		}
+	}
+
+	for _, op := range ops {
+		switch op.Type {
+		case ChangeUpsert:
+			d.write(op.Namespace, op.ID, op.Value, op.TTL, op.Entries)
+		case ChangeDelete:
+			if _, ok := d.items[op.key()]; ok {
+				d.notify(op.Namespace, Change{Type: ChangeDelete, ID: op.ID.String()})
+			}
+			d.delete(op.Namespace, op.ID)
+		}
+	}
+
+	return nil
+}
+
+func (d *MemDriver) Expire(ctx context.Context, namespace string, id ID, ttl time.Duration) error {
+	return d.expire(namespace, id, ttl, nil)
+}
+
+// expire is Expire with a hook that runs once the item is known to exist,
+// with the lock held. Nothing changes if before fails.
+func (d *MemDriver) expire(namespace string, id ID, ttl time.Duration, before func() error) error {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	d.evictIfExpired(namespace, id)
+	if _, ok := d.items[d.generateKey(namespace, id)]; !ok {
+		return constants.ErrorNotFound
+	}
+	if before != nil {
+		if err := before(); err != nil {
+			return err
+		}
+	}
+	d.setTTL(namespace, id, ttl)
+
+	return nil
+}
+
+// record is an item with everything kept about it, as it is saved to disk.
+type record struct {
+	Namespace string
+	ID        string
+	Value     string
+	Version   int64
+	ExpiresAt time.Time
+	Entries   []IndexEntry
+}
+
+// records calls fn with every item that has not expired, with the lock held so
+// nothing changes until fn returns.
+func (d *MemDriver) records(fn func(records []record) error) error {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	var res []record
+	for key, value := range d.items {
+		if d.expired(key) {
+			continue
+		}
+		// Namespaces never contain a colon, so the first one separates the
+		// namespace from the ID.
+		namespace, id, _ := strings.Cut(key, ":")
+		res = append(res, record{
+			Namespace: namespace,
+			ID:        id,
+			Value:     value,
+			Version:   d.versions[key],
+			ExpiresAt: d.expiries[key].at,
+			Entries:   slices.Clone(d.entries[key]),
+		})
+	}
+	slices.SortFunc(res, func(a, b record) int {
+		return strings.Compare(a.Namespace+":"+a.ID, b.Namespace+":"+b.ID)
+	})
+
+	return fn(res)
+}
+
+// restore puts an item back the way it was saved, version and all, unless it
+// has expired since.
+func (d *MemDriver) restore(r record) {
 	d.m.Lock()
 	defer d.m.Unlock()
 
+	d.put(r)
+	d.evictIfExpired(r.Namespace, StringID(r.ID))
+}
+
+// put puts an item back the way it was saved, version and all, even if it has
+// expired since. The lock must be held.
+func (d *MemDriver) put(r record) {
+	id := StringID(r.ID)
+	d.delete(r.Namespace, id)
+	d.write(r.Namespace, id, r.Value, 0, r.Entries)
+	d.versions[d.generateKey(r.Namespace, id)] = r.Version
+	d.setExpiry(r.Namespace, id, r.ExpiresAt)
+}
+
+// write saves the value, bumps its version, refiles it in the indexes and sets
+// its TTL. The lock must be held.
+func (d *MemDriver) write(namespace string, id ID, value string, ttl time.Duration, entries []IndexEntry) {
+	if d.items == nil {
+		d.items = map[string]string{}
+	}
+	if d.versions == nil {
+		d.versions = map[string]int64{}
+	}
 	key := d.generateKey(namespace, id)
 	d.items[key] = value
+	d.versions[key]++
+	d.unindex(namespace, id)
+	d.setTTL(namespace, id, ttl)
+	d.notify(namespace, Change{Type: ChangeUpsert, ID: id.String(), Value: value})
 
-	return nil
+	if len(entries) == 0 {
+		return
+	}
+	if d.indexes == nil {
+		d.indexes = map[string]map[string]float64{}
+		d.entries = map[string][]IndexEntry{}
+	}
+	for _, e := range entries {
+		ik := indexKey(namespace, e.Index, e.Value)
+		if d.indexes[ik] == nil {
+			d.indexes[ik] = map[string]float64{}
+		}
+		d.indexes[ik][id.String()] = e.Score
+	}
+	d.entries[key] = slices.Clone(entries)
 }
 
-func (d *MemDriver) DeleteOne(ctx context.Context, namespace string, id ulid.ULID) error {
+// setTTL makes the item expire after ttl, or never if ttl is 0. The lock must
+// be held.
+func (d *MemDriver) setTTL(namespace string, id ID, ttl time.Duration) {
+	if ttl <= 0 {
+		d.setExpiry(namespace, id, time.Time{})
+		return
+	}
+	d.setExpiry(namespace, id, d.clock().Add(ttl))
+}
+
+// setExpiry makes the item expire at the given time, or never if it is zero.
+// The lock must be held.
+func (d *MemDriver) setExpiry(namespace string, id ID, at time.Time) {
+	key := d.generateKey(namespace, id)
+	if at.IsZero() {
+		delete(d.expiries, key)
+		return
+	}
+
+	if d.expiries == nil {
+		d.expiries = map[string]expiry{}
+	}
+	d.expiries[key] = expiry{namespace: namespace, id: id, at: at}
+	d.sweeper.Do(func() {
+		if d.done == nil {
+			d.done = make(chan struct{})
+		}
+		go d.runSweeper(d.done)
+	})
+}
+
+// unindex removes the item from every index it is filed in. The lock must be
+// held.
+func (d *MemDriver) unindex(namespace string, id ID) {
+	key := d.generateKey(namespace, id)
+	for _, e := range d.entries[key] {
+		ik := indexKey(namespace, e.Index, e.Value)
+		delete(d.indexes[ik], id.String())
+		if len(d.indexes[ik]) == 0 {
+			delete(d.indexes, ik)
+		}
+	}
+	delete(d.entries, key)
+}
+
+func (d *MemDriver) DeleteOne(ctx context.Context, namespace string, id ID) error {
+	return d.Commit(ctx, []Op{{Type: ChangeDelete, Namespace: namespace, ID: id}})
+}
+
+func (d *MemDriver) Watch(ctx context.Context, namespace string) (<-chan Change, error) {
 	d.m.Lock()
 	defer d.m.Unlock()
 
+	ch := make(chan Change, watchBuffer)
+	if d.watchers == nil {
+		d.watchers = map[string]map[chan Change]struct{}{}
+	}
+	if d.watchers[namespace] == nil {
+		d.watchers[namespace] = map[chan Change]struct{}{}
+	}
+	d.watchers[namespace][ch] = struct{}{}
+
+	go func() {
+		<-ctx.Done()
+
+		d.m.Lock()
+		defer d.m.Unlock()
+		d.unwatch(namespace, ch)
+	}()
+
+	return ch, nil
+}
+
+// notify hands the change to everybody watching the namespace, dropping
+// those that have fallen behind. The lock must be held.
+func (d *MemDriver) notify(namespace string, change Change) {
+	for ch := range d.watchers[namespace] {
+		select {
+		case ch <- change:
+		default:
+			d.unwatch(namespace, ch)
+		}
+	}
+}
+
+// unwatch removes and closes a watcher, unless that already happened. The lock
+// must be held.
+func (d *MemDriver) unwatch(namespace string, ch chan Change) {
+	if _, ok := d.watchers[namespace][ch]; !ok {
+		return
+	}
+	delete(d.watchers[namespace], ch)
+	close(ch)
+}
+
+// delete removes the item along with everything kept about it. The lock must
+// be held.
+func (d *MemDriver) delete(namespace string, id ID) {
 	key := d.generateKey(namespace, id)
 	delete(d.items, key)
+	delete(d.versions, key)
+	delete(d.expiries, key)
+	d.unindex(namespace, id)
+}
+
+// expired reports whether the item has outlived its TTL. The lock must be
+// held, for reading at least.
+func (d *MemDriver) expired(key string) bool {
+	e, ok := d.expiries[key]
+	return ok && !d.clock().Before(e.at)
+}
+
+// evictIfExpired deletes the item if it has expired, so a write starts from a
+// clean slate. The lock must be held.
+func (d *MemDriver) evictIfExpired(namespace string, id ID) {
+	if d.expired(d.generateKey(namespace, id)) {
+		d.delete(namespace, id)
+	}
+}
+
+// sweep deletes every expired item.
+func (d *MemDriver) sweep() {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	for key, e := range d.expiries {
+		if d.expired(key) {
+			d.delete(e.namespace, e.id)
+		}
+	}
+}
+
+func (d *MemDriver) runSweeper(done chan struct{}) {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.sweep()
+		case <-done:
+			return
+		}
+	}
+}
+
+func (d *MemDriver) clock() time.Time {
+	if d.now == nil {
+		return time.Now()
+	}
+	return d.now()
+}
+
+// Snapshot saves every item that has not expired to path, replacing whatever
+// was there in one go. Load it back with NewMemDriverWithSnapshots. The items
+// are copied first, so writes only wait for that and not for the disk.
+func (d *MemDriver) Snapshot(path string) error {
+	d.snapshotM.Lock()
+	defer d.snapshotM.Unlock()
+
+	var records []record
+	_ = d.records(func(r []record) error {
+		records = r
+		return nil
+	})
+
+	tmp := path + ".tmp"
+	if _, err := writeLog(tmp, records); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return errors.Wrap(err, "replace snapshot")
+	}
+
+	return syncDir(filepath.Dir(path))
+}
+
+// restoreSnapshot loads the items saved at path, if there is a snapshot
+// there.
+func (d *MemDriver) restoreSnapshot(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "read snapshot")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for dec.More() {
+		var entry logEntry
+		if err = dec.Decode(&entry); err != nil {
+			return errors.Wrapf(err, "decode snapshot %s", path)
+		}
+		if entry.Record != nil {
+			d.restore(*entry.Record)
+		}
+	}
 
 	return nil
 }
 
+func (d *MemDriver) runSnapshots(path string, interval time.Duration, done chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// A failed snapshot leaves the last one in place, and the one
+			// taken on Close reports its error.
+			_ = d.Snapshot(path)
+		case <-done:
+			return
+		}
+	}
+}
+
+// Close stops the sweeper and the snapshots, and takes a last snapshot if the
+// driver keeps them.
+func (d *MemDriver) Close() error {
+	d.m.Lock()
+	closed := false
+	if d.done != nil {
+		select {
+		case <-d.done:
+			closed = true
+		default:
+			close(d.done)
+		}
+	}
+	d.m.Unlock()
+
+	if d.snapshotPath == "" || closed {
+		return nil
+	}
+
+	return d.Snapshot(d.snapshotPath)
+}
+
+// NewMemDriverWithSnapshots returns a MemDriver that starts out with the items
+// in the snapshot at path, if there is one, and saves them there every
+// interval and on Close. An interval of 0 only saves them on Close.
+func NewMemDriverWithSnapshots(path string, interval time.Duration) (*MemDriver, error) {
+	d := NewMemDriver()
+	if err := d.restoreSnapshot(path); err != nil {
+		return nil, err
+	}
+
+	d.snapshotPath = path
+	if interval > 0 {
+		go d.runSnapshots(path, interval, d.done)
+	}
+
+	return d, nil
+}
+
 func NewMemDriver() *MemDriver {
-	return &MemDriver{}
+	return &MemDriver{
+		items:    map[string]string{},
+		versions: map[string]int64{},
+		indexes:  map[string]map[string]float64{},
+		entries:  map[string][]IndexEntry{},
+		expiries: map[string]expiry{},
+		done:     make(chan struct{}),
+		watchers: map[string]map[chan Change]struct{}{},
+	}
 }
@@ -3,7 +3,11 @@ package storage
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/oklog/ulid/v2"
 	"github.com/rBurgett/scmsh/internal/constants"
@@ -49,6 +53,67 @@ func TestMemDriver_FindAll(t *testing.T) {
 	assert.Equal(t, expected, output)
 }
 
+func TestMemDriver_FindPage(t *testing.T) {
+	ctx := context.Background()
+
+	items := map[string]string{
+		// test namespace
+		"test:01JR7AYVRSXV9AYVXV83PCCH1C": `{"some":"thing1"}`,
+		"test:01JR7B1X14X7AKGFH85F88ZNVY": `{"some":"thing2"}`,
+		"test:01JR7B2DEMEY1SYJ1QKM9S9BMF": `{"some":"thing3"}`,
+		// test1 namespace
+		"test1:01JR7B2Y4P0SCVKJJWSE5W8C2J": `{"another":"thing"}`,
+	}
+	md := &MemDriver{
+		items: items,
+	}
+
+	tests := []struct {
+		name         string
+		cursor       string
+		limit        int
+		expected     []string
+		expectedNext string
+	}{
+		{
+			name:         "first page",
+			limit:        2,
+			expected:     []string{`{"some":"thing1"}`, `{"some":"thing2"}`},
+			expectedNext: "01JR7B1X14X7AKGFH85F88ZNVY",
+		},
+		{
+			name:     "last page",
+			cursor:   "01JR7B1X14X7AKGFH85F88ZNVY",
+			limit:    2,
+			expected: []string{`{"some":"thing3"}`},
+		},
+		{
+			name:     "exact page",
+			limit:    3,
+			expected: []string{`{"some":"thing1"}`, `{"some":"thing2"}`, `{"some":"thing3"}`},
+		},
+		{
+			name:     "cursor of a deleted item",
+			cursor:   "01JR7B1X14X7AKGFH85F88ZNVZ",
+			expected: []string{`{"some":"thing3"}`},
+		},
+		{
+			name:     "no limit",
+			expected: []string{`{"some":"thing1"}`, `{"some":"thing2"}`, `{"some":"thing3"}`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, next, err := md.FindPage(ctx, "test", tt.cursor, tt.limit)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expected, output)
+			assert.Equal(t, tt.expectedNext, next)
+		})
+	}
+}
+
 func TestMemDriver_FindOne(t *testing.T) {
 	ctx := context.Background()
 
@@ -100,6 +165,78 @@ func TestMemDriver_FindOne(t *testing.T) {
 	}
 }
 
+func TestMemDriver_FindIndex(t *testing.T) {
+	ctx := context.Background()
+	md := NewMemDriver()
+
+	id1, id2, id3 := ulid.Make(), ulid.Make(), ulid.Make()
+	require.NoError(t, md.UpsertOne(ctx, "test", id1, `{"some":"thing1"}`,
+		IndexEntry{Index: "color", Value: "red", Score: 2},
+		IndexEntry{Index: "shape", Value: "round", Score: 1},
+	))
+	require.NoError(t, md.UpsertOne(ctx, "test", id2, `{"some":"thing2"}`,
+		IndexEntry{Index: "color", Value: "red", Score: 1},
+	))
+	require.NoError(t, md.UpsertOne(ctx, "test", id3, `{"some":"thing3"}`,
+		IndexEntry{Index: "color", Value: "blue", Score: 1},
+	))
+
+	// moving thing3 to red and dropping thing1 has to update the indexes too
+	require.NoError(t, md.CompareAndSwap(ctx, "test", id3, 1, `{"some":"thing3"}`, 0,
+		IndexEntry{Index: "color", Value: "red", Score: 3},
+	))
+	require.NoError(t, md.DeleteOne(ctx, "test", id1))
+
+	tests := []struct {
+		name     string
+		index    string
+		value    string
+		min      float64
+		max      float64
+		expected []string
+	}{
+		{
+			name:     "ordered by score",
+			index:    "color",
+			value:    "red",
+			min:      MinScore,
+			max:      MaxScore,
+			expected: []string{`{"some":"thing2"}`, `{"some":"thing3"}`},
+		},
+		{
+			name:     "score range",
+			index:    "color",
+			value:    "red",
+			min:      2,
+			max:      MaxScore,
+			expected: []string{`{"some":"thing3"}`},
+		},
+		{
+			name:  "moved away",
+			index: "color",
+			value: "blue",
+			min:   MinScore,
+			max:   MaxScore,
+		},
+		{
+			name:  "deleted",
+			index: "shape",
+			value: "round",
+			min:   MinScore,
+			max:   MaxScore,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := md.FindIndex(ctx, "test", tt.index, tt.value, tt.min, tt.max)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expected, output)
+		})
+	}
+}
+
 func TestMemDriver_UpsertOne(t *testing.T) {
 	ctx := context.Background()
 
@@ -202,8 +339,284 @@ func TestMemDriver_DeleteOne(t *testing.T) {
 	}
 }
 
+func TestMemDriver_CompareAndSwap(t *testing.T) {
+	ctx := context.Background()
+	md := NewMemDriver()
+	id := ulid.Make()
+
+	err := md.CompareAndSwap(ctx, "test", id, 1, `{"some":"thing1"}`, 0)
+	assert.ErrorIs(t, err, constants.ErrorConflict)
+
+	err = md.CompareAndSwap(ctx, "test", id, 0, `{"some":"thing1"}`, 0)
+	require.NoError(t, err)
+
+	data, version, err := md.FindOneVersioned(ctx, "test", id)
+	require.NoError(t, err)
+	assert.Equal(t, `{"some":"thing1"}`, data)
+	assert.Equal(t, int64(1), version)
+
+	err = md.UpsertOne(ctx, "test", id, `{"some":"thing2"}`)
+	require.NoError(t, err)
+
+	err = md.CompareAndSwap(ctx, "test", id, version, `{"some":"thing3"}`, 0)
+	assert.ErrorIs(t, err, constants.ErrorConflict)
+
+	err = md.CompareAndSwap(ctx, "test", id, version+1, `{"some":"thing3"}`, 0)
+	require.NoError(t, err)
+
+	data, version, err = md.FindOneVersioned(ctx, "test", id)
+	require.NoError(t, err)
+	assert.Equal(t, `{"some":"thing3"}`, data)
+	assert.Equal(t, int64(3), version)
+
+	require.NoError(t, md.DeleteOne(ctx, "test", id))
+	_, _, err = md.FindOneVersioned(ctx, "test", id)
+	assert.ErrorIs(t, err, constants.ErrorNotFound)
+}
+
+func TestMemDriver_Commit(t *testing.T) {
+	ctx := context.Background()
+	md := NewMemDriver()
+	id1 := ulid.Make()
+	id2 := ulid.Make()
+
+	require.NoError(t, md.UpsertOne(ctx, "a", id1, `{"some":"thing1"}`, IndexEntry{Index: "i", Value: "x"}))
+	_, version, err := md.FindOneVersioned(ctx, "a", id1)
+	require.NoError(t, err)
+
+	err = md.Commit(ctx, []Op{
+		{Type: ChangeUpsert, Namespace: "a", ID: id1, Value: `{"some":"thing2"}`, CheckVersion: true, Version: version + 1},
+		{Type: ChangeUpsert, Namespace: "b", ID: id2, Value: `{"some":"thing3"}`},
+	})
+	assert.ErrorIs(t, err, constants.ErrorConflict)
+	_, err = md.FindOne(ctx, "b", id2)
+	assert.ErrorIs(t, err, constants.ErrorNotFound)
+
+	err = md.Commit(ctx, []Op{
+		{Type: ChangeUpsert, Namespace: "a", ID: id1, Value: `{"some":"thing2"}`, CheckVersion: true, Version: version},
+		{Type: ChangeUpsert, Namespace: "b", ID: id2, Value: `{"some":"thing3"}`, Entries: []IndexEntry{{Index: "i", Value: "y"}}},
+	})
+	require.NoError(t, err)
+
+	data, err := md.FindOne(ctx, "a", id1)
+	require.NoError(t, err)
+	assert.Equal(t, `{"some":"thing2"}`, data)
+	res, err := md.FindIndex(ctx, "a", "i", "x", MinScore, MaxScore)
+	require.NoError(t, err)
+	assert.Empty(t, res)
+	res, err = md.FindIndex(ctx, "b", "i", "y", MinScore, MaxScore)
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"some":"thing3"}`}, res)
+
+	err = md.Commit(ctx, []Op{
+		{Type: ChangeDelete, Namespace: "a", ID: id1},
+		{Type: ChangeDelete, Namespace: "b", ID: id2},
+	})
+	require.NoError(t, err)
+	_, err = md.FindOne(ctx, "a", id1)
+	assert.ErrorIs(t, err, constants.ErrorNotFound)
+	_, err = md.FindOne(ctx, "b", id2)
+	assert.ErrorIs(t, err, constants.ErrorNotFound)
+
+	err = md.Commit(ctx, []Op{
+		{Type: ChangeUpsert, Namespace: "a", ID: id1, Value: `{"some":"thing1"}`},
+		{Type: ChangeDelete, Namespace: "a", ID: id1},
+	})
+	assert.ErrorIs(t, err, ErrorDuplicateOp)
+}
+
+func TestMemDriver_ttl(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	md := NewMemDriver()
+	md.now = func() time.Time { return now }
+	defer md.Close()
+
+	id1, id2 := ulid.Make(), ulid.Make()
+	require.NoError(t, md.UpsertOneTTL(ctx, "test", id1, `{"some":"thing1"}`, time.Minute,
+		IndexEntry{Index: "color", Value: "red"},
+	))
+	require.NoError(t, md.UpsertOne(ctx, "test", id2, `{"some":"thing2"}`,
+		IndexEntry{Index: "color", Value: "red"},
+	))
+	require.NoError(t, md.Expire(ctx, "test", id2, 2*time.Minute))
+	assert.ErrorIs(t, md.Expire(ctx, "test", ulid.Make(), time.Minute), constants.ErrorNotFound)
+
+	output, err := md.FindIndex(ctx, "test", "color", "red", MinScore, MaxScore)
+	require.NoError(t, err)
+	assert.Len(t, output, 2)
+
+	now = now.Add(time.Minute)
+
+	_, err = md.FindOne(ctx, "test", id1)
+	assert.ErrorIs(t, err, constants.ErrorNotFound)
+
+	output, err = md.FindAll(ctx, "test")
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"some":"thing2"}`}, output)
+
+	output, err = md.FindIndex(ctx, "test", "color", "red", MinScore, MaxScore)
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"some":"thing2"}`}, output)
+
+	// an expired item can be created again from scratch
+	require.NoError(t, md.CompareAndSwap(ctx, "test", id1, 0, `{"some":"thing3"}`, 0))
+
+	// clearing the TTL keeps the item for good
+	require.NoError(t, md.Expire(ctx, "test", id2, 0))
+	now = now.Add(time.Hour)
+	md.sweep()
+
+	assert.Len(t, md.items, 2)
+	assert.Empty(t, md.expiries)
+}
+
+func TestMemDriver_Watch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	md := NewMemDriver()
+
+	changes, err := md.Watch(ctx, "test")
+	require.NoError(t, err)
+
+	id := ulid.Make()
+	require.NoError(t, md.UpsertOne(ctx, "test", id, `{"some":"thing1"}`))
+	require.NoError(t, md.UpsertOne(ctx, "test1", ulid.Make(), `{"another":"thing"}`))
+	require.NoError(t, md.DeleteOne(ctx, "test", id))
+	require.NoError(t, md.DeleteOne(ctx, "test", ulid.Make()))
+
+	assert.Equal(t, Change{Type: ChangeUpsert, ID: id.String(), Value: `{"some":"thing1"}`}, <-changes)
+	assert.Equal(t, Change{Type: ChangeDelete, ID: id.String()}, <-changes)
+
+	cancel()
+	for range changes {
+	}
+}
+
+func TestMemDriver_Watch_slow(t *testing.T) {
+	ctx := context.Background()
+	md := NewMemDriver()
+
+	changes, err := md.Watch(ctx, "test")
+	require.NoError(t, err)
+
+	for range watchBuffer + 1 {
+		require.NoError(t, md.UpsertOne(ctx, "test", ulid.Make(), `{"some":"thing"}`))
+	}
+
+	var n int
+	for range changes {
+		n++
+	}
+	assert.Equal(t, watchBuffer, n)
+}
+
+func TestMemDriver_Snapshot(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "snapshot")
+	md := NewMemDriver()
+	defer md.Close()
+	id1 := ulid.Make()
+	id2 := ulid.Make()
+	id3 := ulid.Make()
+
+	require.NoError(t, md.UpsertOne(ctx, "a", id1, `{"some":"thing1"}`, IndexEntry{Index: "i", Value: "x", Score: 1}))
+	require.NoError(t, md.UpsertOne(ctx, "a", id1, `{"some":"thing2"}`, IndexEntry{Index: "i", Value: "x", Score: 1}))
+	require.NoError(t, md.UpsertOneTTL(ctx, "b", id2, `{"some":"thing3"}`, time.Hour))
+	require.NoError(t, md.UpsertOneTTL(ctx, "b", id3, `{"some":"thing4"}`, 50*time.Millisecond))
+	require.NoError(t, md.Snapshot(path))
+
+	time.Sleep(60 * time.Millisecond)
+	restored, err := NewMemDriverWithSnapshots(path, 0)
+	require.NoError(t, err)
+	defer restored.Close()
+
+	data, version, err := restored.FindOneVersioned(ctx, "a", id1)
+	require.NoError(t, err)
+	assert.Equal(t, `{"some":"thing2"}`, data)
+	assert.Equal(t, int64(2), version)
+	res, err := restored.FindIndex(ctx, "a", "i", "x", MinScore, MaxScore)
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"some":"thing2"}`}, res)
+	_, err = restored.FindOne(ctx, "b", id2)
+	assert.NoError(t, err)
+	_, err = restored.FindOne(ctx, "b", id3)
+	assert.ErrorIs(t, err, constants.ErrorNotFound)
+}
+
+func TestMemDriver_Snapshot_concurrent(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "snapshot")
+	md := NewMemDriver()
+	defer md.Close()
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 10 {
+				assert.NoError(t, md.UpsertOne(ctx, "test", ulid.Make(), `{"some":"thing"}`))
+				assert.NoError(t, md.Snapshot(path))
+			}
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, md.Snapshot(path))
+
+	restored, err := NewMemDriverWithSnapshots(path, 0)
+	require.NoError(t, err)
+	defer restored.Close()
+	res, err := restored.FindAll(ctx, "test")
+	require.NoError(t, err)
+	assert.Len(t, res, 40)
+}
+
+func TestNewMemDriverWithSnapshots(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "snapshot")
+	id1 := ulid.Make()
+	id2 := ulid.Make()
+
+	md, err := NewMemDriverWithSnapshots(path, 10*time.Millisecond)
+	require.NoError(t, err)
+	res, err := md.FindAll(ctx, "test")
+	require.NoError(t, err)
+	assert.Empty(t, res)
+
+	require.NoError(t, md.UpsertOne(ctx, "test", id1, `{"some":"thing1"}`))
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, md.UpsertOne(ctx, "test", id2, `{"some":"thing2"}`))
+	require.NoError(t, md.Close())
+
+	md, err = NewMemDriverWithSnapshots(path, 0)
+	require.NoError(t, err)
+	defer md.Close()
+	res, err = md.FindAll(ctx, "test")
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"some":"thing1"}`, `{"some":"thing2"}`}, res)
+
+	require.NoError(t, os.WriteFile(path, []byte("garbage"), 0o600))
+	_, err = NewMemDriverWithSnapshots(path, 0)
+	assert.Error(t, err)
+}
+
 func TestNewMemDriver(t *testing.T) {
 	output := NewMemDriver()
-
 	require.NotNil(t, output)
+
+	// Ready to write to straight away.
+	require.NoError(t, output.UpsertOne(context.Background(), "test", ulid.Make(), `{"some":"thing"}`))
+
+	// So is the zero value.
+	var zero MemDriver
+	id := ulid.Make()
+	require.NoError(t, zero.UpsertOneTTL(context.Background(), "test", id, `{"some":"thing"}`, time.Hour, IndexEntry{Index: "i", Value: "x"}))
+	data, err := zero.FindOne(context.Background(), "test", id)
+	require.NoError(t, err)
+	assert.Equal(t, `{"some":"thing"}`, data)
+	require.NoError(t, zero.Close())
 }
@@ -2,56 +2,148 @@ package storage
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"sort"
+	"slices"
+	"strings"
+	"time"
 
-	"github.com/oklog/ulid/v2"
 	"github.com/pkg/errors"
 	"github.com/rBurgett/scmsh/internal/config"
 	"github.com/rBurgett/scmsh/internal/constants"
 	"github.com/redis/go-redis/v9"
 )
 
+const (
+	// scanCount is the batch size used for SCAN and MGET.
+	scanCount = 100
+	// writeAttempts is how many times a blind write is retried while it
+	// races with other writes to the same record.
+	writeAttempts = 5
+)
+
 type RedisDriver struct {
 	client *redis.Client
 }
 
-func (d *RedisDriver) generateKey(namespace string, id ulid.ULID) string {
+func (d *RedisDriver) generateKey(namespace string, id ID) string {
 	return fmt.Sprintf("%s:%s", namespace, id)
 }
 
+// versionKey and entriesKey hold the version and the index entries of a
+// record. There is no colon right after the namespace, so FindAll never sees
+// them.
+func (d *RedisDriver) versionKey(namespace string, id ID) string {
+	return fmt.Sprintf("%s.version:%s", namespace, id)
+}
+
+// changesChannel is the pub/sub channel changes to the namespace are
+// published on.
+func (d *RedisDriver) changesChannel(namespace string) string {
+	return fmt.Sprintf("%s.changes", namespace)
+}
+
+func (d *RedisDriver) entriesKey(namespace string, id ID) string {
+	return fmt.Sprintf("%s.entries:%s", namespace, id)
+}
+
+// idsKey is a sorted set of the IDs in the namespace, all with a score of 0 so
+// they are ordered by ID and can be paged through with ZRANGEBYLEX. Records
+// written before it was kept are added to it once, the first time the
+// namespace is paged through, after which idsCompleteKey is set.
+func (d *RedisDriver) idsKey(namespace string) string {
+	return fmt.Sprintf("%s.ids", namespace)
+}
+
+func (d *RedisDriver) idsCompleteKey(namespace string) string {
+	return fmt.Sprintf("%s.ids.complete", namespace)
+}
+
 func (d *RedisDriver) FindAll(ctx context.Context, namespace string) (res []string, err error) {
-	prefix := fmt.Sprintf("%s:*", namespace)
-	keys, err := d.client.Keys(ctx, prefix).Result()
-	if err != nil {
-		return nil, err
+	res, _, err = d.FindPage(ctx, namespace, "", 0)
+	return res, err
+}
+
+// FindPage reads the page of IDs after the cursor from the namespace's sorted
+// set of IDs and fetches them with MGET in batches, so a page costs about as
+// much as its size. IDs of records that have expired are dropped from the set
+// as they come up, and more are read in their place.
+func (d *RedisDriver) FindPage(ctx context.Context, namespace string, cursor string, limit int) (res []string, next string, err error) {
+	if err = d.completeIDs(ctx, namespace); err != nil {
+		return nil, "", err
 	}
 
-	type kv struct {
-		k string
-		v string
+	start := "-"
+	if cursor != "" {
+		start = "(" + cursor
 	}
-	var items []kv
-	for _, k := range keys {
-		v, err := d.client.Get(ctx, k).Result()
+	for {
+		by := &redis.ZRangeBy{Min: start, Max: "+"}
+		if limit > 0 {
+			// One more than is needed tells whether there is another page.
+			by.Count = int64(limit - len(res) + 1)
+		}
+		ids, err := d.client.ZRangeByLex(ctx, d.idsKey(namespace), by).Result()
+		if err != nil {
+			return nil, "", err
+		}
+		more := limit > 0 && len(ids) > limit-len(res)
+		if more {
+			ids = ids[:limit-len(res)]
+		}
+
+		found, missing, err := d.getAll(ctx, namespace, ids)
 		if err != nil {
-			return nil, err
+			return nil, "", err
+		}
+		res = append(res, found...)
+		if err = d.unindexMissing(ctx, namespace, d.idsKey(namespace), missing); err != nil {
+			return nil, "", err
+		}
+
+		switch {
+		case !more:
+			return res, "", nil
+		case len(missing) == 0:
+			return res, ids[len(ids)-1], nil
 		}
-		items = append(items, kv{k: k, v: v})
+		start = "(" + ids[len(ids)-1]
 	}
+}
 
-	sort.Slice(items, func(i, j int) bool {
-		return items[i].k < items[j].k
-	})
+// completeIDs adds the records of the namespace that are not in its set of IDs
+// yet, the first time it is called for the namespace. The namespace is walked
+// with SCAN rather than KEYS so Redis is never blocked.
+func (d *RedisDriver) completeIDs(ctx context.Context, namespace string) error {
+	n, err := d.client.Exists(ctx, d.idsCompleteKey(namespace)).Result()
+	if err != nil || n > 0 {
+		return err
+	}
 
-	for _, i := range items {
-		res = append(res, i.v)
+	prefix := fmt.Sprintf("%s:", namespace)
+	var members []redis.Z
+	iter := d.client.Scan(ctx, 0, prefix+"*", scanCount).Iterator()
+	for iter.Next(ctx) {
+		members = append(members, redis.Z{Member: strings.TrimPrefix(iter.Val(), prefix)})
+	}
+	if err = iter.Err(); err != nil {
+		return err
 	}
 
-	return res, nil
+	// Records deleted in the meantime may be added too, but FindPage drops
+	// them again when it comes across them.
+	_, err = d.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for batch := range slices.Chunk(members, scanCount) {
+			pipe.ZAdd(ctx, d.idsKey(namespace), batch...)
+		}
+		pipe.Set(ctx, d.idsCompleteKey(namespace), 1, 0)
+		return nil
+	})
+
+	return err
 }
 
-func (d *RedisDriver) FindOne(ctx context.Context, namespace string, id ulid.ULID) (string, error) {
+func (d *RedisDriver) FindOne(ctx context.Context, namespace string, id ID) (string, error) {
 	key := d.generateKey(namespace, id)
 	val, err := d.client.Get(ctx, key).Result()
 	if err != nil {
@@ -65,27 +157,308 @@ func (d *RedisDriver) FindOne(ctx context.Context, namespace string, id ulid.ULI
 	return val, nil
 }
 
-func (d *RedisDriver) UpsertOne(ctx context.Context, namespace string, id ulid.ULID, value string) error {
-	key := d.generateKey(namespace, id)
+func (d *RedisDriver) FindOneVersioned(ctx context.Context, namespace string, id ID) (string, int64, error) {
+	var val *redis.StringCmd
+	var version *redis.StringCmd
+	_, err := d.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		val = pipe.Get(ctx, d.generateKey(namespace, id))
+		version = pipe.Get(ctx, d.versionKey(namespace, id))
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return "", 0, err
+	}
+
+	res, err := val.Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", 0, constants.ErrorNotFound
+		}
+		return "", 0, err
+	}
+
+	v, err := version.Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return "", 0, errors.Wrap(err, "invalid record version")
+	}
+
+	return res, v, nil
+}
 
-	err := d.client.Set(ctx, key, value, 0).Err()
+func (d *RedisDriver) FindIndex(ctx context.Context, namespace string, index string, value string, min float64, max float64) (res []string, err error) {
+	ids, err := d.client.ZRangeByScore(ctx, indexKey(namespace, index, value), &redis.ZRangeBy{
+		Min: scoreArg(min),
+		Max: scoreArg(max),
+	}).Result()
 	if err != nil {
+		return nil, err
+	}
+
+	res, missing, err := d.getAll(ctx, namespace, ids)
+	if err != nil {
+		return nil, err
+	}
+	if err = d.unindexMissing(ctx, namespace, indexKey(namespace, index, value), missing); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (d *RedisDriver) UpsertOne(ctx context.Context, namespace string, id ID, value string, entries ...IndexEntry) error {
+	return d.UpsertOneTTL(ctx, namespace, id, value, 0, entries...)
+}
+
+func (d *RedisDriver) UpsertOneTTL(ctx context.Context, namespace string, id ID, value string, ttl time.Duration, entries ...IndexEntry) error {
+	return d.Commit(ctx, []Op{{Type: ChangeUpsert, Namespace: namespace, ID: id, Value: value, TTL: ttl, Entries: entries}})
+}
+
+func (d *RedisDriver) CompareAndSwap(ctx context.Context, namespace string, id ID, version int64, value string, ttl time.Duration, entries ...IndexEntry) error {
+	return d.Commit(ctx, []Op{{Type: ChangeUpsert, Namespace: namespace, ID: id, Value: value, TTL: ttl, Entries: entries, CheckVersion: true, Version: version}})
+}
+
+func (d *RedisDriver) DeleteOne(ctx context.Context, namespace string, id ID) error {
+	return d.Commit(ctx, []Op{{Type: ChangeDelete, Namespace: namespace, ID: id}})
+}
+
+// Commit applies the writes in a single MULTI/EXEC. A transaction that races
+// with another write is retried, unless it checks versions, in which case the
+// race is a conflict.
+func (d *RedisDriver) Commit(ctx context.Context, ops []Op) error {
+	if err := checkOps(ops); err != nil {
 		return err
 	}
 
-	return nil
+	versioned := slices.ContainsFunc(ops, func(op Op) bool {
+		return op.CheckVersion
+	})
+	for range writeAttempts {
+		err := d.commit(ctx, ops)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+		if versioned {
+			break
+		}
+	}
+
+	return constants.ErrorConflict
 }
 
-func (d *RedisDriver) DeleteOne(ctx context.Context, namespace string, id ulid.ULID) error {
-	key := d.generateKey(namespace, id)
-	err := d.client.Del(ctx, key).Err()
+// commit watches every record the transaction touches, along with their
+// versions and index entries, checks the versions and then writes. Records
+// are taken out of their indexes before they are written, so write only has
+// to file them under their new entries.
+func (d *RedisDriver) commit(ctx context.Context, ops []Op) error {
+	var keys []string
+	for _, op := range ops {
+		keys = append(keys, d.generateKey(op.Namespace, op.ID), d.versionKey(op.Namespace, op.ID), d.entriesKey(op.Namespace, op.ID))
+	}
+
+	return d.client.Watch(ctx, func(tx *redis.Tx) error {
+		old := make([][]string, len(ops))
+		for i, op := range ops {
+			if op.CheckVersion {
+				current, err := tx.Get(ctx, d.versionKey(op.Namespace, op.ID)).Int64()
+				if err != nil && !errors.Is(err, redis.Nil) {
+					return err
+				}
+				if current != op.Version {
+					return constants.ErrorConflict
+				}
+			}
+
+			var err error
+			old[i], err = tx.SMembers(ctx, d.entriesKey(op.Namespace, op.ID)).Result()
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, op := range ops {
+				d.unindex(ctx, pipe, op.Namespace, op.ID, old[i])
+				switch op.Type {
+				case ChangeUpsert:
+					d.write(ctx, pipe, op.Namespace, op.ID, op.Value, op.TTL, op.Entries)
+				case ChangeDelete:
+					pipe.Del(ctx, d.generateKey(op.Namespace, op.ID), d.versionKey(op.Namespace, op.ID))
+					pipe.ZRem(ctx, d.idsKey(op.Namespace), op.ID.String())
+					d.publish(ctx, pipe, op.Namespace, Change{Type: ChangeDelete, ID: op.ID.String()})
+				}
+			}
+			return nil
+		})
+		return err
+	}, keys...)
+}
+
+// unindex queues taking the record out of the indexes listed in its entries.
+func (d *RedisDriver) unindex(ctx context.Context, pipe redis.Pipeliner, namespace string, id ID, entries []string) {
+	for _, member := range entries {
+		index, value, _ := strings.Cut(member, ":")
+		pipe.ZRem(ctx, indexKey(namespace, index, value), id.String())
+	}
+	pipe.Del(ctx, d.entriesKey(namespace, id))
+}
+
+// Expire makes the record expire after ttl, or never if ttl is 0, along with
+// its version and the list of its index entries. Redis cannot take it out of
+// the indexes when it expires, so FindIndex does that when it comes across it.
+func (d *RedisDriver) Expire(ctx context.Context, namespace string, id ID, ttl time.Duration) error {
+	var found *redis.BoolCmd
+	_, err := d.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		found = d.expire(ctx, pipe, d.generateKey(namespace, id), ttl)
+		d.expire(ctx, pipe, d.versionKey(namespace, id), ttl)
+		d.expire(ctx, pipe, d.entriesKey(namespace, id), ttl)
+		return nil
+	})
 	if err != nil {
 		return err
 	}
+	if !found.Val() && ttl > 0 {
+		return constants.ErrorNotFound
+	}
 
 	return nil
 }
 
+func (d *RedisDriver) expire(ctx context.Context, pipe redis.Pipeliner, key string, ttl time.Duration) *redis.BoolCmd {
+	if ttl <= 0 {
+		return pipe.Persist(ctx, key)
+	}
+	return pipe.Expire(ctx, key, ttl)
+}
+
+// unindexMissing takes records that have expired out of their indexes and the
+// set of IDs. The list of their entries may have expired with them, so they
+// are taken out of setKey, the sorted set they were found in, either way.
+func (d *RedisDriver) unindexMissing(ctx context.Context, namespace string, setKey string, ids []string) error {
+	for _, s := range ids {
+		id := StringID(s)
+		key := d.generateKey(namespace, id)
+		entriesKey := d.entriesKey(namespace, id)
+
+		err := d.client.Watch(ctx, func(tx *redis.Tx) error {
+			n, err := tx.Exists(ctx, key).Result()
+			if err != nil || n > 0 {
+				return err
+			}
+			entries, err := tx.SMembers(ctx, entriesKey).Result()
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				d.unindex(ctx, pipe, namespace, id, entries)
+				pipe.ZRem(ctx, setKey, s)
+				pipe.ZRem(ctx, d.idsKey(namespace), s)
+				return nil
+			})
+			return err
+		}, key, entriesKey)
+		if err != nil && !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// write queues saving the value, adding it to the set of IDs, bumping its
+// version, setting its TTL and filing it under its index entries.
+func (d *RedisDriver) write(ctx context.Context, pipe redis.Pipeliner, namespace string, id ID, value string, ttl time.Duration, entries []IndexEntry) {
+	pipe.Set(ctx, d.generateKey(namespace, id), value, ttl)
+	pipe.ZAdd(ctx, d.idsKey(namespace), redis.Z{Member: id.String()})
+	pipe.Incr(ctx, d.versionKey(namespace, id))
+	d.expire(ctx, pipe, d.versionKey(namespace, id), ttl)
+	for _, e := range entries {
+		pipe.ZAdd(ctx, indexKey(namespace, e.Index, e.Value), redis.Z{Score: e.Score, Member: id.String()})
+		pipe.SAdd(ctx, d.entriesKey(namespace, id), fmt.Sprintf("%s:%s", e.Index, e.Value))
+	}
+	if len(entries) > 0 && ttl > 0 {
+		pipe.Expire(ctx, d.entriesKey(namespace, id), ttl)
+	}
+	d.publish(ctx, pipe, namespace, Change{Type: ChangeUpsert, ID: id.String(), Value: value})
+}
+
+// publish queues announcing the change, so it goes out with the write it
+// describes or not at all.
+func (d *RedisDriver) publish(ctx context.Context, pipe redis.Pipeliner, namespace string, change Change) {
+	// Marshaling a struct of strings cannot fail.
+	msg, _ := json.Marshal(change)
+	pipe.Publish(ctx, d.changesChannel(namespace), msg)
+}
+
+func (d *RedisDriver) Watch(ctx context.Context, namespace string) (<-chan Change, error) {
+	pubsub := d.client.Subscribe(ctx, d.changesChannel(namespace))
+	// Wait for the subscription to be confirmed so no change made after
+	// Watch returns is missed.
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	res := make(chan Change, watchBuffer)
+	go func() {
+		defer close(res)
+		defer pubsub.Close()
+
+		msgs := pubsub.Channel()
+		for {
+			select {
+			case msg, ok := <-msgs:
+				if !ok {
+					return
+				}
+				var change Change
+				if err := json.Unmarshal([]byte(msg.Payload), &change); err != nil {
+					continue
+				}
+				select {
+				case res <- change:
+				default:
+					// Fallen behind.
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return res, nil
+}
+
+// getAll fetches the records with the given IDs in batches, skipping and
+// returning the IDs of any that have been deleted in the meantime.
+func (d *RedisDriver) getAll(ctx context.Context, namespace string, ids []string) (res []string, missing []string, err error) {
+	for batch := range slices.Chunk(ids, scanCount) {
+		keys := make([]string, len(batch))
+		for i, id := range batch {
+			keys[i] = fmt.Sprintf("%s:%s", namespace, id)
+		}
+
+		values, err := d.client.MGet(ctx, keys...).Result()
+		if err != nil {
+			return nil, nil, err
+		}
+		for i, v := range values {
+			if s, ok := v.(string); ok {
+				res = append(res, s)
+			} else {
+				missing = append(missing, batch[i])
+			}
+		}
+	}
+
+	return res, missing, nil
+}
+
+func (d *RedisDriver) Close() error {
+	return d.client.Close()
+}
+
 func NewRedisDriver(cfg config.Config) *RedisDriver {
 	client := redis.NewClient(&redis.Options{
 		Addr:     cfg.RedisAddress,
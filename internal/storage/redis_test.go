@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/alicebob/miniredis/v2"
 	"github.com/oklog/ulid/v2"
@@ -63,6 +64,100 @@ func TestRedisDriver_FindAll(t *testing.T) {
 	assert.Equal(t, expected, output)
 }
 
+func TestRedisDriver_FindPage(t *testing.T) {
+	ctx := context.Background()
+
+	s := miniredis.RunT(t)
+	require.NoError(t, s.Set("test:01JR7AYVRSXV9AYVXV83PCCH1C", `{"some":"thing1"}`))
+	require.NoError(t, s.Set("test:01JR7B1X14X7AKGFH85F88ZNVY", `{"some":"thing2"}`))
+	require.NoError(t, s.Set("test:01JR7B2DEMEY1SYJ1QKM9S9BMF", `{"some":"thing3"}`))
+	require.NoError(t, s.Set("test1:01JR7B2Y4P0SCVKJJWSE5W8C2J", `{"another":"thing"}`))
+
+	d := NewRedisDriver(config.Config{
+		RedisAddress: s.Addr(),
+	})
+
+	tests := []struct {
+		name         string
+		cursor       string
+		limit        int
+		expected     []string
+		expectedNext string
+	}{
+		{
+			name:         "first page",
+			limit:        2,
+			expected:     []string{`{"some":"thing1"}`, `{"some":"thing2"}`},
+			expectedNext: "01JR7B1X14X7AKGFH85F88ZNVY",
+		},
+		{
+			name:     "last page",
+			cursor:   "01JR7B1X14X7AKGFH85F88ZNVY",
+			limit:    2,
+			expected: []string{`{"some":"thing3"}`},
+		},
+		{
+			name:     "exact page",
+			limit:    3,
+			expected: []string{`{"some":"thing1"}`, `{"some":"thing2"}`, `{"some":"thing3"}`},
+		},
+		{
+			name:     "cursor of a deleted item",
+			cursor:   "01JR7B1X14X7AKGFH85F88ZNVZ",
+			expected: []string{`{"some":"thing3"}`},
+		},
+		{
+			name:     "no limit",
+			expected: []string{`{"some":"thing1"}`, `{"some":"thing2"}`, `{"some":"thing3"}`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, next, err := d.FindPage(ctx, "test", tt.cursor, tt.limit)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expected, output)
+			assert.Equal(t, tt.expectedNext, next)
+		})
+	}
+}
+
+func TestRedisDriver_FindPage_ids(t *testing.T) {
+	ctx := context.Background()
+
+	s := miniredis.RunT(t)
+	d := NewRedisDriver(config.Config{
+		RedisAddress: s.Addr(),
+	})
+
+	ids := []ulid.ULID{ulid.Make(), ulid.Make(), ulid.Make(), ulid.Make(), ulid.Make()}
+	for i, id := range ids {
+		ttl := time.Duration(0)
+		if i == 1 || i == 2 {
+			ttl = time.Minute
+		}
+		require.NoError(t, d.UpsertOneTTL(ctx, "test", id, id.String(), ttl))
+	}
+	require.NoError(t, d.DeleteOne(ctx, "test", ids[4]))
+	s.FastForward(time.Minute)
+
+	// The expired records are skipped and the page filled up from after them.
+	output, next, err := d.FindPage(ctx, "test", "", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{ids[0].String(), ids[3].String()}, output)
+	assert.Empty(t, next)
+
+	members, err := s.ZMembers(d.idsKey("test"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{ids[0].String(), ids[3].String()}, members)
+
+	output, next, err = d.FindPage(ctx, "test", "", 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{ids[0].String()}, output)
+	assert.Equal(t, ids[0].String(), next)
+}
+
 func TestRedisDriver_FindOne(t *testing.T) {
 	ctx := context.Background()
 
@@ -125,6 +220,86 @@ func TestRedisDriver_FindOne(t *testing.T) {
 	}
 }
 
+func TestRedisDriver_FindIndex(t *testing.T) {
+	ctx := context.Background()
+	s := miniredis.RunT(t)
+	d := NewRedisDriver(config.Config{
+		RedisAddress: s.Addr(),
+	})
+
+	id1, id2, id3 := ulid.Make(), ulid.Make(), ulid.Make()
+	require.NoError(t, d.UpsertOne(ctx, "test", id1, `{"some":"thing1"}`,
+		IndexEntry{Index: "color", Value: "red", Score: 2},
+		IndexEntry{Index: "shape", Value: "round", Score: 1},
+	))
+	require.NoError(t, d.UpsertOne(ctx, "test", id2, `{"some":"thing2"}`,
+		IndexEntry{Index: "color", Value: "red", Score: 1},
+	))
+	require.NoError(t, d.UpsertOne(ctx, "test", id3, `{"some":"thing3"}`,
+		IndexEntry{Index: "color", Value: "blue", Score: 1},
+	))
+
+	// moving thing3 to red and dropping thing1 has to update the indexes too
+	require.NoError(t, d.CompareAndSwap(ctx, "test", id3, 1, `{"some":"thing3"}`, 0,
+		IndexEntry{Index: "color", Value: "red", Score: 3},
+	))
+	require.NoError(t, d.DeleteOne(ctx, "test", id1))
+
+	tests := []struct {
+		name     string
+		index    string
+		value    string
+		min      float64
+		max      float64
+		expected []string
+	}{
+		{
+			name:     "ordered by score",
+			index:    "color",
+			value:    "red",
+			min:      MinScore,
+			max:      MaxScore,
+			expected: []string{`{"some":"thing2"}`, `{"some":"thing3"}`},
+		},
+		{
+			name:     "score range",
+			index:    "color",
+			value:    "red",
+			min:      2,
+			max:      MaxScore,
+			expected: []string{`{"some":"thing3"}`},
+		},
+		{
+			name:  "moved away",
+			index: "color",
+			value: "blue",
+			min:   MinScore,
+			max:   MaxScore,
+		},
+		{
+			name:  "deleted",
+			index: "shape",
+			value: "round",
+			min:   MinScore,
+			max:   MaxScore,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := d.FindIndex(ctx, "test", tt.index, tt.value, tt.min, tt.max)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expected, output)
+		})
+	}
+
+	keys := s.Keys()
+	assert.NotContains(t, keys, indexKey("test", "color", "blue"))
+	assert.NotContains(t, keys, indexKey("test", "shape", "round"))
+	assert.NotContains(t, keys, d.entriesKey("test", id1))
+}
+
 func TestRedisDriver_UpsertOne(t *testing.T) {
 	ctx := context.Background()
 
@@ -250,6 +425,202 @@ func TestRedisDriver_DeleteOne(t *testing.T) {
 	}
 }
 
+func TestRedisDriver_CompareAndSwap(t *testing.T) {
+	ctx := context.Background()
+	s := miniredis.RunT(t)
+	d := NewRedisDriver(config.Config{
+		RedisAddress: s.Addr(),
+	})
+	id := ulid.Make()
+
+	err := d.CompareAndSwap(ctx, "test", id, 1, `{"some":"thing1"}`, 0)
+	assert.ErrorIs(t, err, constants.ErrorConflict)
+
+	err = d.CompareAndSwap(ctx, "test", id, 0, `{"some":"thing1"}`, 0)
+	require.NoError(t, err)
+
+	data, version, err := d.FindOneVersioned(ctx, "test", id)
+	require.NoError(t, err)
+	assert.Equal(t, `{"some":"thing1"}`, data)
+	assert.Equal(t, int64(1), version)
+
+	err = d.UpsertOne(ctx, "test", id, `{"some":"thing2"}`)
+	require.NoError(t, err)
+
+	err = d.CompareAndSwap(ctx, "test", id, version, `{"some":"thing3"}`, 0)
+	assert.ErrorIs(t, err, constants.ErrorConflict)
+
+	err = d.CompareAndSwap(ctx, "test", id, version+1, `{"some":"thing3"}`, 0)
+	require.NoError(t, err)
+
+	data, version, err = d.FindOneVersioned(ctx, "test", id)
+	require.NoError(t, err)
+	assert.Equal(t, `{"some":"thing3"}`, data)
+	assert.Equal(t, int64(3), version)
+
+	require.NoError(t, d.DeleteOne(ctx, "test", id))
+	_, _, err = d.FindOneVersioned(ctx, "test", id)
+	assert.ErrorIs(t, err, constants.ErrorNotFound)
+}
+
+func TestRedisDriver_CompareAndSwap_legacy(t *testing.T) {
+	ctx := context.Background()
+
+	s := miniredis.RunT(t)
+	d := NewRedisDriver(config.Config{
+		RedisAddress: s.Addr(),
+	})
+
+	id := ulid.Make()
+	require.NoError(t, s.Set(fmt.Sprintf("test:%s", id), `{"some":"thing1"}`))
+
+	_, version, err := d.FindOneVersioned(ctx, "test", id)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), version)
+
+	err = d.CompareAndSwap(ctx, "test", id, version, `{"some":"thing2"}`, 0)
+	require.NoError(t, err)
+
+	output, err := d.FindAll(ctx, "test")
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"some":"thing2"}`}, output)
+}
+
+func TestRedisDriver_Commit(t *testing.T) {
+	ctx := context.Background()
+	s := miniredis.RunT(t)
+	d := NewRedisDriver(config.Config{
+		RedisAddress: s.Addr(),
+	})
+	id1 := ulid.Make()
+	id2 := ulid.Make()
+
+	require.NoError(t, d.UpsertOne(ctx, "a", id1, `{"some":"thing1"}`, IndexEntry{Index: "i", Value: "x"}))
+	_, version, err := d.FindOneVersioned(ctx, "a", id1)
+	require.NoError(t, err)
+
+	err = d.Commit(ctx, []Op{
+		{Type: ChangeUpsert, Namespace: "a", ID: id1, Value: `{"some":"thing2"}`, CheckVersion: true, Version: version + 1},
+		{Type: ChangeUpsert, Namespace: "b", ID: id2, Value: `{"some":"thing3"}`},
+	})
+	assert.ErrorIs(t, err, constants.ErrorConflict)
+	_, err = d.FindOne(ctx, "b", id2)
+	assert.ErrorIs(t, err, constants.ErrorNotFound)
+
+	err = d.Commit(ctx, []Op{
+		{Type: ChangeUpsert, Namespace: "a", ID: id1, Value: `{"some":"thing2"}`, CheckVersion: true, Version: version},
+		{Type: ChangeUpsert, Namespace: "b", ID: id2, Value: `{"some":"thing3"}`, Entries: []IndexEntry{{Index: "i", Value: "y"}}},
+	})
+	require.NoError(t, err)
+
+	data, err := d.FindOne(ctx, "a", id1)
+	require.NoError(t, err)
+	assert.Equal(t, `{"some":"thing2"}`, data)
+	res, err := d.FindIndex(ctx, "a", "i", "x", MinScore, MaxScore)
+	require.NoError(t, err)
+	assert.Empty(t, res)
+	res, err = d.FindIndex(ctx, "b", "i", "y", MinScore, MaxScore)
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"some":"thing3"}`}, res)
+
+	err = d.Commit(ctx, []Op{
+		{Type: ChangeDelete, Namespace: "a", ID: id1},
+		{Type: ChangeDelete, Namespace: "b", ID: id2},
+	})
+	require.NoError(t, err)
+	_, err = d.FindOne(ctx, "a", id1)
+	assert.ErrorIs(t, err, constants.ErrorNotFound)
+	_, err = d.FindOne(ctx, "b", id2)
+	assert.ErrorIs(t, err, constants.ErrorNotFound)
+
+	err = d.Commit(ctx, []Op{
+		{Type: ChangeUpsert, Namespace: "a", ID: id1, Value: `{"some":"thing1"}`},
+		{Type: ChangeDelete, Namespace: "a", ID: id1},
+	})
+	assert.ErrorIs(t, err, ErrorDuplicateOp)
+}
+
+func TestRedisDriver_ttl(t *testing.T) {
+	ctx := context.Background()
+
+	s := miniredis.RunT(t)
+	d := NewRedisDriver(config.Config{
+		RedisAddress: s.Addr(),
+	})
+
+	id1, id2 := ulid.Make(), ulid.Make()
+	require.NoError(t, d.UpsertOneTTL(ctx, "test", id1, `{"some":"thing1"}`, time.Minute,
+		IndexEntry{Index: "color", Value: "red"},
+	))
+	require.NoError(t, d.UpsertOne(ctx, "test", id2, `{"some":"thing2"}`,
+		IndexEntry{Index: "color", Value: "red"},
+	))
+	require.NoError(t, d.Expire(ctx, "test", id2, 2*time.Minute))
+	assert.ErrorIs(t, d.Expire(ctx, "test", ulid.Make(), time.Minute), constants.ErrorNotFound)
+	assert.Equal(t, time.Minute, s.TTL(d.entriesKey("test", id1)))
+	assert.Equal(t, 2*time.Minute, s.TTL(d.entriesKey("test", id2)))
+
+	s.FastForward(time.Minute)
+
+	_, err := d.FindOne(ctx, "test", id1)
+	assert.ErrorIs(t, err, constants.ErrorNotFound)
+
+	output, err := d.FindIndex(ctx, "test", "color", "red", MinScore, MaxScore)
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"some":"thing2"}`}, output)
+
+	// FindIndex cleans up after the expired record
+	members, err := s.ZMembers(indexKey("test", "color", "red"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{id2.String()}, members)
+	assert.False(t, s.Exists(d.entriesKey("test", id1)))
+
+	require.NoError(t, d.Expire(ctx, "test", id2, 0))
+	s.FastForward(time.Hour)
+
+	_, err = d.FindOne(ctx, "test", id2)
+	require.NoError(t, err)
+}
+
+func TestRedisDriver_Watch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s := miniredis.RunT(t)
+	d := NewRedisDriver(config.Config{
+		RedisAddress: s.Addr(),
+	})
+	// a second driver stands in for another server sharing the same Redis
+	other := NewRedisDriver(config.Config{
+		RedisAddress: s.Addr(),
+	})
+
+	changes, err := d.Watch(ctx, "test")
+	require.NoError(t, err)
+
+	id := ulid.Make()
+	require.NoError(t, other.UpsertOne(ctx, "test", id, `{"some":"thing1"}`))
+	require.NoError(t, other.UpsertOne(ctx, "test1", ulid.Make(), `{"another":"thing"}`))
+	require.NoError(t, other.CompareAndSwap(ctx, "test", id, 1, `{"some":"thing2"}`, 0))
+	require.NoError(t, other.DeleteOne(ctx, "test", id))
+
+	for _, expected := range []Change{
+		{Type: ChangeUpsert, ID: id.String(), Value: `{"some":"thing1"}`},
+		{Type: ChangeUpsert, ID: id.String(), Value: `{"some":"thing2"}`},
+		{Type: ChangeDelete, ID: id.String()},
+	} {
+		select {
+		case change := <-changes:
+			assert.Equal(t, expected, change)
+		case <-time.After(time.Second):
+			require.Fail(t, "missed a change")
+		}
+	}
+
+	cancel()
+	for range changes {
+	}
+}
+
 func TestNewRedisDriver(t *testing.T) {
 	cfg := config.Config{
 		RedisAddress:  "redisaddress:1234",
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrorDuplicateOp = errors.New("record written twice in one transaction")
+	ErrorMixedDriver = errors.New("transaction spans drivers")
+)
+
+// Op is one write in a transaction: an upsert or a delete of a single record.
+// With CheckVersion set, the whole transaction fails with
+// constants.ErrorConflict unless the record is still at Version.
+type Op struct {
+	Type         ChangeType
+	Namespace    string
+	ID           ID
+	Value        string
+	TTL          time.Duration
+	Entries      []IndexEntry
+	CheckVersion bool
+	Version      int64
+}
+
+func (op Op) key() string {
+	return op.Namespace + ":" + op.ID.String()
+}
+
+// checkOps rejects transactions that write the same record twice.
+func checkOps(ops []Op) error {
+	seen := map[string]bool{}
+	for _, op := range ops {
+		if seen[op.key()] {
+			return errors.Wrap(ErrorDuplicateOp, op.key())
+		}
+		seen[op.key()] = true
+	}
+
+	return nil
+}
+
+// Tx collects writes from any number of clients sharing a driver, to be
+// committed together. Nothing is written until Commit.
+type Tx struct {
+	driver Driver
+	ops    []Op
+}
+
+func (tx *Tx) add(driver Driver, op Op) error {
+	if tx.driver == nil {
+		tx.driver = driver
+	}
+	if tx.driver != driver {
+		return ErrorMixedDriver
+	}
+	tx.ops = append(tx.ops, op)
+
+	return nil
+}
+
+// Commit applies every write in the transaction, or none of them.
+func (tx *Tx) Commit(ctx context.Context) error {
+	if len(tx.ops) == 0 {
+		return nil
+	}
+
+	return tx.driver.Commit(ctx, tx.ops)
+}
+
+func NewTx() *Tx {
+	return &Tx{}
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rBurgett/scmsh/internal/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTx(t *testing.T) {
+	ctx := context.Background()
+	driver := NewMemDriver()
+	records := NewClient[testRecord](driver, "records")
+	names := NewClient[string](driver, "names", WithCodec(GobCodec))
+
+	r := newTestRecord()
+	require.NoError(t, records.UpsertOne(ctx, r.ID, r))
+	_, version, err := records.FindOneVersioned(ctx, r.ID)
+	require.NoError(t, err)
+
+	tx := NewTx()
+	r.Name = "changed"
+	require.NoError(t, records.TxCompareAndSwap(tx, r.ID, version, r))
+	require.NoError(t, names.TxUpsert(tx, r.ID, r.Name))
+	require.NoError(t, tx.Commit(ctx))
+
+	found, err := records.FindOne(ctx, r.ID)
+	require.NoError(t, err)
+	assert.Equal(t, r, found)
+	name, err := names.FindOne(ctx, r.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "changed", name)
+
+	tx = NewTx()
+	require.NoError(t, records.TxCompareAndSwap(tx, r.ID, version, r))
+	require.NoError(t, names.TxDelete(tx, r.ID))
+	assert.ErrorIs(t, tx.Commit(ctx), constants.ErrorConflict)
+	_, err = names.FindOne(ctx, r.ID)
+	assert.NoError(t, err)
+
+	tx = NewTx()
+	require.NoError(t, records.TxCompareAndDelete(tx, r.ID, version))
+	assert.ErrorIs(t, tx.Commit(ctx), constants.ErrorConflict)
+	_, version, err = records.FindOneVersioned(ctx, r.ID)
+	require.NoError(t, err)
+
+	tx = NewTx()
+	require.NoError(t, records.TxCompareAndDelete(tx, r.ID, version))
+	require.NoError(t, tx.Commit(ctx))
+	_, err = records.FindOne(ctx, r.ID)
+	assert.ErrorIs(t, err, constants.ErrorNotFound)
+
+	other := NewClient[string](NewMemDriver(), "names")
+	assert.ErrorIs(t, other.TxDelete(tx, r.ID), ErrorMixedDriver)
+
+	assert.NoError(t, NewTx().Commit(ctx))
+}
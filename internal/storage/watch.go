@@ -0,0 +1,29 @@
+package storage
+
+type ChangeType string
+
+const (
+	ChangeUpsert ChangeType = "upsert"
+	ChangeDelete ChangeType = "delete"
+
+	// watchBuffer is how many changes a watcher can fall behind by before it
+	// is dropped.
+	watchBuffer = 64
+)
+
+// Change tells a watcher that a record was written or deleted. Value is the
+// record as stored, and empty for deletes.
+type Change struct {
+	Type  ChangeType
+	ID    string
+	Value string
+}
+
+// TypedChange is a Change with the value decoded. Err is set if it could not
+// be.
+type TypedChange[T any] struct {
+	Type  ChangeType
+	ID    string
+	Value T
+	Err   error
+}
@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Watch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	driver := NewMemDriver()
+	client := NewClient[testRecord](driver, "test", WithCodec(GzipJSONCodec))
+
+	changes, err := client.Watch(ctx)
+	require.NoError(t, err)
+
+	r := newTestRecord()
+	require.NoError(t, client.UpsertOne(ctx, r.ID, r))
+	require.NoError(t, driver.UpsertOne(ctx, "test", r.ID, "not a record"))
+	require.NoError(t, client.DeleteOne(ctx, r.ID))
+
+	change := <-changes
+	require.NoError(t, change.Err)
+	assert.Equal(t, ChangeUpsert, change.Type)
+	assert.Equal(t, r.ID.String(), change.ID)
+	assert.Equal(t, r, change.Value)
+
+	change = <-changes
+	assert.Error(t, change.Err)
+
+	change = <-changes
+	require.NoError(t, change.Err)
+	assert.Equal(t, ChangeDelete, change.Type)
+	assert.Equal(t, r.ID.String(), change.ID)
+}